@@ -0,0 +1,204 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// answersVersion of a reloaded file must match, otherwise the current answers are kept
+const answersVersion = 1
+
+// answersReload is how often an answers file is checked for changes
+const answersReload = time.Minute
+
+// defaultAnswers are built into the skill and used when no answers file is configured
+//
+//go:embed answers.json
+var defaultAnswers []byte
+
+// answersFile is a versioned set of answer variants for every locale
+type answersFile struct {
+	Version       int                            `json:"version"`
+	DefaultLocale string                         `json:"defaultLocale"`
+	Locales       map[string]map[string][]string `json:"locales"`
+}
+
+// AnswerData contains values of placeholders available in answer templates
+type AnswerData struct {
+	Movie     string
+	Cinema    string
+	Time      string
+	City      string
+	Subway    string
+	Address   string
+	Day       string
+	Count     int
+	Choices   string
+	Movies    string
+	Showtimes string
+	// the second spoken time of a cinema or a movie
+	NextTime string
+	// the spoken format of a showtime, empty for a usual one
+	Format string
+}
+
+// Phrases renders an answer in the locale of the user, parts of answers built in code are rendered with it
+type Phrases func(tag string, data AnswerData) string
+
+// answerFuncs are functions available in answer templates: {{plural .Count `минуту` `минуты` `минут`}}
+var answerFuncs = template.FuncMap{"plural": plural}
+
+// AnswerCatalog keeps answer templates of every locale, a random variant of the answer is said every time.
+// Catalog loaded from a file can be reloaded when the file changes.
+type AnswerCatalog struct {
+	path          string
+	tags          []string
+	mu            sync.RWMutex
+	defaultLocale string
+	locales       map[string]map[string][]*template.Template
+	modified      time.Time
+}
+
+// DefaultAnswers returns answers built into the skill
+func DefaultAnswers() *AnswerCatalog {
+	c := &AnswerCatalog{tags: answerTags}
+	if err := c.parse(defaultAnswers); err != nil {
+		panic("built-in answers are broken: " + err.Error())
+	}
+	return c
+}
+
+// LoadAnswers loads answers from a file and checks that every tag is present in the default locale
+func LoadAnswers(path string, tags []string) (*AnswerCatalog, error) {
+	c := &AnswerCatalog{path: path, tags: tags}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.load(info.ModTime()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Watch reloads the answers file when it changes, it never returns.
+// A broken file is reported and previous answers are kept.
+func (c *AnswerCatalog) Watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		info, err := os.Stat(c.path)
+		if err != nil {
+			log.Printf("[WARN] Failed to check answers file %s: %v", c.path, err)
+			continue
+		}
+		c.mu.RLock()
+		changed := !info.ModTime().Equal(c.modified)
+		c.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := c.load(info.ModTime()); err != nil {
+			log.Printf("[ERROR] Failed to reload answers from %s, keeping previous ones: %v", c.path, err)
+			c.mu.Lock()
+			c.modified = info.ModTime()
+			c.mu.Unlock()
+			continue
+		}
+		log.Printf("[INFO] Reloaded answers from %s", c.path)
+	}
+}
+
+func (c *AnswerCatalog) load(modified time.Time) error {
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return err
+	}
+	if err := c.parse(data); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.modified = modified
+	c.mu.Unlock()
+	return nil
+}
+
+// parse replaces answers of the catalog only if the whole file is valid
+func (c *AnswerCatalog) parse(data []byte) error {
+	var file answersFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	if file.Version != answersVersion {
+		return fmt.Errorf("unsupported answers version %d", file.Version)
+	}
+	if _, ok := file.Locales[file.DefaultLocale]; !ok {
+		return fmt.Errorf("no answers for the default locale %q", file.DefaultLocale)
+	}
+
+	locales := make(map[string]map[string][]*template.Template, len(file.Locales))
+	for locale, answers := range file.Locales {
+		locales[locale] = make(map[string][]*template.Template, len(answers))
+		for tag, variants := range answers {
+			for i, variant := range variants {
+				parsed, err := parseAnswer(variant)
+				if err != nil {
+					return fmt.Errorf("%s %s variant %d: %v", locale, tag, i+1, err)
+				}
+				locales[locale][tag] = append(locales[locale][tag], parsed)
+			}
+		}
+	}
+	for _, tag := range c.tags {
+		if len(locales[file.DefaultLocale][tag]) == 0 {
+			return fmt.Errorf("no answer %s in the default locale %q", tag, file.DefaultLocale)
+		}
+	}
+
+	c.mu.Lock()
+	c.defaultLocale = file.DefaultLocale
+	c.locales = locales
+	c.mu.Unlock()
+	return nil
+}
+
+// parseAnswer parses a template and checks that it uses only known placeholders
+func parseAnswer(text string) (*template.Template, error) {
+	parsed, err := template.New("").Funcs(answerFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := parsed.Execute(ioutil.Discard, AnswerData{}); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// Answer returns a random variant of the answer with filled placeholders.
+// Unknown locales and answers missing in the locale fall back to the default locale.
+func (c *AnswerCatalog) Answer(locale, tag string, data AnswerData) string {
+	c.mu.RLock()
+	variants := c.locales[locale][tag]
+	if len(variants) == 0 {
+		variants = c.locales[c.defaultLocale][tag]
+	}
+	c.mu.RUnlock()
+	if len(variants) == 0 {
+		log.Printf("[ERROR] No answer %s for locale %q", tag, locale)
+		return ""
+	}
+
+	var answer strings.Builder
+	if err := variants[rand.Intn(len(variants))].Execute(&answer, data); err != nil {
+		log.Printf("[ERROR] Failed to render answer %s: %v", tag, err)
+		return ""
+	}
+	return answer.String()
+}
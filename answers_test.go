@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultAnswers(t *testing.T) {
+	answers := DefaultAnswers()
+	for _, tag := range answerTags {
+		if answer := answers.Answer("ru-RU", tag, AnswerData{}); answer == "" {
+			t.Errorf("empty answer %s", tag)
+		}
+	}
+
+	data := AnswerData{City: "Москва", Subway: "Сокол"}
+	if answer := answers.Answer("ru-RU", "YOUR_ADDRESS", data); answer != "Ваш адрес: город Москва, метро Сокол" {
+		t.Errorf("wrong address: %s", answer)
+	}
+	if answer := answers.Answer("ru-RU", "YOUR_ADDRESS", AnswerData{City: "Абакан"}); answer != "Ваш адрес: город Абакан" {
+		t.Errorf("wrong address without subway: %s", answer)
+	}
+	if answer := answers.Answer("en-US", "YOUR_ADDRESS", data); answer != "Ваш адрес: город Москва, метро Сокол" {
+		t.Errorf("unknown locale should fall back to the default one: %s", answer)
+	}
+}
+
+func writeAnswers(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadAnswers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "answers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "answers.json")
+
+	var td = []struct {
+		Content string
+		Error   string
+	}{
+		{`{"version": 2, "defaultLocale": "ru-RU", "locales": {"ru-RU": {"WELCOME": ["Привет"]}}}`, "version"},
+		{`{"version": 1, "defaultLocale": "ru-RU", "locales": {"en-US": {"WELCOME": ["Hi"]}}}`, "default locale"},
+		{`{"version": 1, "defaultLocale": "ru-RU", "locales": {"ru-RU": {"HELLO": ["Привет"]}}}`, "WELCOME"},
+		{`{"version": 1, "defaultLocale": "ru-RU", "locales": {"ru-RU": {"WELCOME": ["Привет, {{.Name}}"]}}}`, "Name"},
+		{`{"version": 1, "defaultLocale": "ru-RU", "locales": {"ru-RU": {"WELCOME": ["Привет, {{.City"]}}}`, "WELCOME"},
+	}
+	for _, tr := range td {
+		writeAnswers(t, path, tr.Content)
+		if _, err := LoadAnswers(path, []string{"WELCOME"}); err == nil || !strings.Contains(err.Error(), tr.Error) {
+			t.Errorf("expected error about %s for %s, got %v", tr.Error, tr.Content, err)
+		}
+	}
+
+	writeAnswers(t, path, `{"version": 1, "defaultLocale": "ru-RU", "locales": {
+		"ru-RU": {"WELCOME": ["Привет, {{.City}}"], "BYE": ["Пока"]},
+		"en-US": {"WELCOME": ["Hi, {{.City}}"]}
+	}}`)
+	answers, err := LoadAnswers(path, []string{"WELCOME", "BYE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer := answers.Answer("en-US", "WELCOME", AnswerData{City: "Moscow"}); answer != "Hi, Moscow" {
+		t.Errorf("wrong localized answer: %s", answer)
+	}
+	if answer := answers.Answer("en-US", "BYE", AnswerData{}); answer != "Пока" {
+		t.Errorf("missing answer should fall back to the default locale: %s", answer)
+	}
+
+	// a broken file is not applied
+	writeAnswers(t, path, `{"version": 1, "defaultLocale": "ru-RU", "locales": {"ru-RU": {"WELCOME": ["Здравствуйте"]}}}`)
+	if err := answers.load(time.Now()); err == nil {
+		t.Fatal("answers without BYE should not be loaded")
+	}
+	if answer := answers.Answer("ru-RU", "WELCOME", AnswerData{City: "Москва"}); answer != "Привет, Москва" {
+		t.Errorf("previous answers should be kept: %s", answer)
+	}
+
+	writeAnswers(t, path, `{"version": 1, "defaultLocale": "ru-RU", "locales": {"ru-RU": {"WELCOME": ["Здравствуйте"], "BYE": ["Пока"]}}}`)
+	if err := answers.load(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if answer := answers.Answer("en-US", "WELCOME", AnswerData{}); answer != "Здравствуйте" {
+		t.Errorf("reloaded answers should replace locales: %s", answer)
+	}
+}
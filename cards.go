@@ -0,0 +1,78 @@
+package main
+
+import "strings"
+
+// Alice doesn't show more items in an ItemsList card
+const maxCardItems = 5
+
+// a screen fits more showtimes of a cinema than a voice answer
+const showtimesPerCard = 5
+
+// showtimesCard lists cinemas of the page with their showtimes.
+// A single cinema is shown on the movie poster if it is uploaded.
+func showtimesCard(movie, day string, page []Cinema, posterID string) *Card {
+	if len(page) == 1 && posterID != "" {
+		cinema := page[0]
+		return &Card{
+			Type:        "BigImage",
+			ImageID:     posterID,
+			Title:       "«" + movie + "» в " + cinema.Name,
+			Description: day + ": " + cardShowtimes(cinema.Showtimes),
+			Button:      ticketCardButton(cinema.Showtimes),
+		}
+	}
+
+	card := &Card{Type: "ItemsList", Header: &CardHeader{"«" + movie + "», " + day}}
+	for i, cinema := range page {
+		if i == maxCardItems {
+			break
+		}
+		description := cardShowtimes(cinema.Showtimes)
+		if cinema.Subway != "" {
+			description += "\nм. " + cinema.Subway
+		}
+		card.Items = append(card.Items, CardItem{
+			Title:       cinema.Name,
+			Description: description,
+			Button:      ticketCardButton(cinema.Showtimes),
+		})
+	}
+	return card
+}
+
+// scheduleCard lists movies of a cinema with their showtimes
+func scheduleCard(cinema, day string, movies []MovieShowtimes) *Card {
+	card := &Card{Type: "ItemsList", Header: &CardHeader{cinema + ", " + day}}
+	for i, movie := range movies {
+		if i == maxCardItems {
+			break
+		}
+		card.Items = append(card.Items, CardItem{
+			Title:       movie.Movie,
+			Description: cardShowtimes(movie.Showtimes),
+			Button:      ticketCardButton(movie.Showtimes),
+		})
+	}
+	return card
+}
+
+func cardShowtimes(showtimes []Showtime) string {
+	descriptions := make([]string, 0, showtimesPerCard)
+	for i, showtime := range showtimes {
+		if i == showtimesPerCard {
+			break
+		}
+		descriptions = append(descriptions, describeShowtime(showtime))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// ticketCardButton opens the ticket page of the first showtime that has one
+func ticketCardButton(showtimes []Showtime) *CardButton {
+	for _, showtime := range showtimes {
+		if showtime.TicketURL != "" {
+			return &CardButton{Text: "Купить билет", URL: showtime.TicketURL}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+type stubImages map[string]string
+
+func (s stubImages) ImageID(url string) (string, bool) {
+	id, ok := s[url]
+	return id, ok
+}
+
+func TestShowtimesCard(t *testing.T) {
+	page := []Cinema{
+		{Name: "Октябрь", Subway: "Арбатская", Showtimes: []Showtime{testTicket(18, ""), testTicket(20, "https://tickets/2")}},
+		{Name: "Пионер", Showtimes: []Showtime{testTicket(19, "")}},
+	}
+
+	card := showtimesCard("Пассажир", "сегодня", page, "poster")
+	if card.Type != "ItemsList" || len(card.Items) != 2 || card.Header.Text != "«Пассажир», сегодня" {
+		t.Fatalf("wrong cinemas card: %+v", card)
+	}
+	if card.Items[0].Description != "18:00, 20:00\nм. Арбатская" || card.Items[0].Button.URL != "https://tickets/2" || card.Items[1].Button != nil {
+		t.Fatalf("wrong cinema item: %+v", card.Items)
+	}
+
+	card = showtimesCard("Пассажир", "сегодня", page[:1], "poster")
+	if card.Type != "BigImage" || card.ImageID != "poster" || card.Title != "«Пассажир» в Октябрь" {
+		t.Fatalf("wrong poster card: %+v", card)
+	}
+	if card = showtimesCard("Пассажир", "сегодня", page[:1], ""); card.Type != "ItemsList" {
+		t.Fatalf("a card without an uploaded poster should be a list: %+v", card)
+	}
+}
+
+func TestScreenCards(t *testing.T) {
+	storage := NewStorage()
+	storage.Save("user", &Location{State: StateIdle, City: "Москва"})
+	processor := NewProcessor(storage, dialogParser{}, nil)
+	processor.SetImageStore(stubImages{})
+
+	request := dialogRequest("cards", "расписание пассажира", false)
+	if response := processor.Process(request); response.Response.Card != nil {
+		t.Fatalf("devices without a screen should not get cards")
+	}
+	request.Meta.Interfaces.Screen = &struct{}{}
+	if response := processor.Process(request); response.Response.Card == nil || response.Response.Card.Type != "ItemsList" {
+		t.Fatalf("devices with a screen should get a card: %+v", response.Response.Card)
+	}
+}
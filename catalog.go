@@ -0,0 +1,182 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minimal confidence to search a matched movie without asking the user
+const confidentMatch = 0.8
+
+// minimal confidence to suggest a matched movie to the user
+const possibleMatch = 0.55
+
+// a city which failed to load is not loaded again for this time, so requests don't wait for a broken provider
+const catalogRetry = time.Minute
+
+type catalogEntry struct {
+	movies  []Movie
+	cinemas []Cinema
+	// the city is loaded again after this
+	expires time.Time
+}
+
+// catalogLoad is a load of a city in progress, other requests for the city wait for it
+type catalogLoad struct {
+	done  chan struct{}
+	entry *catalogEntry
+}
+
+// MovieCatalog keeps movies currently in distribution and cinemas for every city users asked about.
+// Cities are loaded on the first request and refreshed in background.
+type MovieCatalog struct {
+	lister  MovieLister
+	refresh time.Duration
+	mu      sync.RWMutex
+	cities  map[string]*catalogEntry
+	loading map[string]*catalogLoad
+}
+
+// NewMovieCatalog creates an empty catalog which refreshes cities with a given interval.
+// Cinemas are known only if the lister is a CinemaParser too.
+func NewMovieCatalog(lister MovieLister, refresh time.Duration) *MovieCatalog {
+	return &MovieCatalog{
+		lister:  lister,
+		refresh: refresh,
+		cities:  make(map[string]*catalogEntry),
+		loading: make(map[string]*catalogLoad),
+	}
+}
+
+// Run refreshes all known cities periodically, it never returns
+func (c *MovieCatalog) Run() {
+	for range time.Tick(c.refresh) {
+		c.mu.RLock()
+		cities := make([]string, 0, len(c.cities))
+		for city := range c.cities {
+			cities = append(cities, city)
+		}
+		c.mu.RUnlock()
+
+		for _, city := range cities {
+			c.load(city)
+		}
+	}
+}
+
+// Movies returns movies showing in the city, loading them if the city is unknown yet
+func (c *MovieCatalog) Movies(city string) []Movie {
+	return c.entry(city).movies
+}
+
+// Repertoire returns the most popular movies in the city: movies with more showtimes go first,
+// otherwise the order of providers is kept
+func (c *MovieCatalog) Repertoire(city string, count int) []Movie {
+	movies := append([]Movie{}, c.Movies(city)...)
+	sort.SliceStable(movies, func(i, j int) bool {
+		return movies[i].Showtimes > movies[j].Showtimes
+	})
+	if len(movies) > count {
+		movies = movies[:count]
+	}
+	return movies
+}
+
+// Cinemas returns cinemas of the city, loading them if the city is unknown yet
+func (c *MovieCatalog) Cinemas(city string) []Cinema {
+	return c.entry(city).cinemas
+}
+
+func (c *MovieCatalog) entry(city string) *catalogEntry {
+	key := strings.ToLower(city)
+	c.mu.RLock()
+	entry, ok := c.cities[key]
+	c.mu.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry
+	}
+	return c.load(key)
+}
+
+// load loads the city once for all concurrent requests
+func (c *MovieCatalog) load(city string) *catalogEntry {
+	c.mu.Lock()
+	if load, ok := c.loading[city]; ok {
+		c.mu.Unlock()
+		<-load.done
+		return load.entry
+	}
+	load := &catalogLoad{done: make(chan struct{})}
+	c.loading[city] = load
+	// stale data is still better than nothing
+	entry, ok := c.cities[city]
+	c.mu.Unlock()
+	if !ok {
+		entry = &catalogEntry{movies: []Movie{}, cinemas: []Cinema{}}
+	}
+
+	load.entry = c.fetch(city, entry)
+	c.mu.Lock()
+	c.cities[city] = load.entry
+	delete(c.loading, city)
+	c.mu.Unlock()
+	close(load.done)
+	return load.entry
+}
+
+// fetch loads movies and cinemas of the city, a failed city keeps the previous data for a while
+func (c *MovieCatalog) fetch(city string, entry *catalogEntry) *catalogEntry {
+	loaded := &catalogEntry{movies: entry.movies, cinemas: entry.cinemas, expires: time.Now().Add(2 * c.refresh)}
+
+	movies, err := c.lister.GetMovies(city)
+	if err != nil {
+		log.Printf("[WARN] Failed to load movies for %s: %v", city, err)
+		loaded.expires = time.Now().Add(catalogRetry)
+		return loaded
+	}
+	log.Printf("[INFO] Loaded %d movies for %s", len(movies), city)
+	loaded.movies = movies
+
+	if cinemaParser, ok := c.lister.(CinemaParser); ok {
+		cinemas, err := cinemaParser.GetCinemas(city)
+		if err != nil {
+			log.Printf("[WARN] Failed to load cinemas for %s: %v", city, err)
+		} else {
+			log.Printf("[INFO] Loaded %d cinemas for %s", len(cinemas), city)
+			loaded.cinemas = cinemas
+		}
+	}
+	return loaded
+}
+
+// Resolve finds movies in the city that match the user query best and returns them with a confidence.
+// Several movies are returned when they match equally well: "пантера" for "Черная пантера" and "Розовая пантера".
+func (c *MovieCatalog) Resolve(city, query string) ([]Movie, float64, bool) {
+	var best []Movie
+	bestScore := 0.0
+	for _, movie := range c.Movies(city) {
+		score := MatchScore(query, movie.Title)
+		if score > bestScore {
+			best, bestScore = []Movie{movie}, score
+		} else if score == bestScore && score > 0 {
+			best = append(best, movie)
+		}
+	}
+	return best, bestScore, bestScore > 0
+}
+
+// ResolveCinema finds a cinema in the city that matches the user query best and returns it with a confidence
+func (c *MovieCatalog) ResolveCinema(city, query string) (Cinema, float64, bool) {
+	var best Cinema
+	bestScore := 0.0
+	normalizedQuery := normalizeCinemaName(query)
+	for _, cinema := range c.Cinemas(city) {
+		if score := MatchScore(normalizedQuery, normalizeCinemaName(cinema.Name)); score > bestScore {
+			best, bestScore = cinema, score
+		}
+	}
+	return best, bestScore, bestScore > 0
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// how many movies are shown to the user to choose from
+const maxChoices = 3
+
+// ordinal words in all genders used to pick a choice: "первый", "вторая", "номер три"
+var choiceOrdinals = map[string]int{
+	"первый": 0, "первая": 0, "первое": 0, "первого": 0, "первую": 0, "один": 0, "1": 0,
+	"второй": 1, "вторая": 1, "второе": 1, "второго": 1, "вторую": 1, "два": 1, "2": 1,
+	"третий": 2, "третья": 2, "третье": 2, "третьего": 2, "третью": 2, "три": 2, "3": 2,
+}
+
+var lastChoiceWords = map[string]bool{
+	"последний": true, "последняя": true, "последнее": true, "последнего": true, "последнюю": true,
+}
+
+// words pointing at one of offered places: "тот, который в Новосибирске"
+var locationChoiceWords = map[string]bool{
+	"тот": true, "та": true, "то": true, "который": true, "которая": true, "которое": true, "что": true, "где": true,
+}
+
+// choiceLabel is a button title for the movie, the year helps to tell remakes apart
+func choiceLabel(movie Movie) string {
+	if movie.Year == 0 {
+		return movie.Title
+	}
+	return movie.Title + " (" + strconv.Itoa(movie.Year) + ")"
+}
+
+func choiceLabels(movies []Movie) []string {
+	labels := make([]string, 0, len(movies))
+	for _, movie := range movies {
+		labels = append(labels, choiceLabel(movie))
+	}
+	return labels
+}
+
+// SelectChoice finds which of the offered movies the user has chosen:
+// by a button, an ordinal number, a year or a title
+func SelectChoice(phrase string, choices []Movie) (int, bool) {
+	if len(choices) == 0 {
+		return 0, false
+	}
+	if len(choices) == 1 && isAgreement(phrase) {
+		return 0, true
+	}
+
+	// button pressed
+	for i, choice := range choices {
+		if strings.EqualFold(strings.TrimSpace(phrase), choiceLabel(choice)) {
+			return i, true
+		}
+	}
+
+	// exact title, "оно 2" is a title and not the second choice
+	normalized := strings.Join(cleanTitle(phrase), " ")
+	found := -1
+	for i, choice := range choices {
+		if strings.Join(cleanTitle(choice.Title), " ") != normalized {
+			continue
+		}
+		if found != -1 {
+			found = -1
+			break
+		}
+		found = i
+	}
+	if found != -1 {
+		return found, true
+	}
+
+	words := splitWords(phrase)
+	if index, ok := selectOrdinal(words, len(choices)); ok {
+		return index, true
+	}
+
+	// "который 2018 года"
+	for _, word := range words {
+		year, err := strconv.Atoi(word)
+		if err != nil || len(word) != 4 {
+			continue
+		}
+		found := -1
+		for i, choice := range choices {
+			if choice.Year != year {
+				continue
+			}
+			if found != -1 {
+				// several movies of the same year, the year doesn't help
+				found = -1
+				break
+			}
+			found = i
+		}
+		if found != -1 {
+			return found, true
+		}
+	}
+
+	// the title itself, but only if it is clearly better than others
+	best, bestScore, secondScore := -1, 0.0, 0.0
+	for i, choice := range choices {
+		score := MatchScore(phrase, choice.Title)
+		if score > bestScore {
+			best, bestScore, secondScore = i, score, bestScore
+		} else if score > secondScore {
+			secondScore = score
+		}
+	}
+	if best != -1 && bestScore >= confidentMatch && bestScore-secondScore > ambiguityDelta {
+		return best, true
+	}
+	return 0, false
+}
+
+// selectOrdinal finds an ordinal number of one of count choices among words
+func selectOrdinal(words []string, count int) (int, bool) {
+	for _, word := range words {
+		if index, ok := choiceOrdinals[word]; ok && index < count {
+			return index, true
+		}
+		if lastChoiceWords[word] {
+			return count - 1, true
+		}
+	}
+	return 0, false
+}
+
+// locationLabel is a button title for the location, the region tells apart towns with the same name
+func locationLabel(location Location) string {
+	label := location.City
+	if location.Region != "" {
+		label += " (" + location.Region + ")"
+	}
+	if location.Subway != "" {
+		label += ", метро " + location.Subway
+	}
+	return label
+}
+
+func locationLabels(locations []Location) []string {
+	labels := make([]string, 0, len(locations))
+	for _, location := range locations {
+		labels = append(labels, locationLabel(location))
+	}
+	return labels
+}
+
+// SelectLocationChoice finds which of the offered locations the user has chosen:
+// by a button, an ordinal number or a city or region named in the phrase
+func SelectLocationChoice(phrase string, choices []Location) (int, bool) {
+	if len(choices) == 0 {
+		return 0, false
+	}
+	if len(choices) == 1 && isAgreement(phrase) {
+		return 0, true
+	}
+
+	// button pressed
+	for i, choice := range choices {
+		if strings.EqualFold(strings.TrimSpace(phrase), locationLabel(choice)) {
+			return i, true
+		}
+	}
+
+	words := splitWords(phrase)
+	if index, ok := selectOrdinal(words, len(choices)); ok {
+		return index, true
+	}
+
+	// "в Новосибирске", "который в Мурманской области", but not another address "Москва, метро Сокол"
+	stemmed := stems(phrase)
+	found := -1
+	for i, choice := range choices {
+		used := make([]bool, len(words))
+		name := stems(choice.City)
+		if choice.Region != "" {
+			// the first word is enough: "Мурманская область"
+			name = stems(choice.Region)[:1]
+		}
+		if _, ok := findWords(stemmed, used, name); !ok {
+			continue
+		}
+		for _, word := range stems(choice.City + " " + choice.Subway + " " + choice.Region) {
+			for j := range stemmed {
+				if stemmed[j] == word {
+					used[j] = true
+				}
+			}
+		}
+		for j, word := range words {
+			if !used[j] && !locationFillers[word] && !locationChoiceWords[word] {
+				return 0, false
+			}
+		}
+		if found != -1 {
+			return 0, false
+		}
+		found = i
+	}
+	return found, found != -1
+}
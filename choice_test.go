@@ -0,0 +1,80 @@
+package main
+
+import "testing"
+
+func TestSelectChoice(t *testing.T) {
+	choices := []Movie{
+		{Title: "Оно", Year: 2017},
+		{Title: "Оно", Year: 1990},
+		{Title: "Оно 2", Year: 2019},
+	}
+	var td = []struct {
+		Phrase string
+		Index  int
+		Ok     bool
+	}{
+		{"Оно (1990)", 1, true},
+		{"первый", 0, true},
+		{"давай вторую", 1, true},
+		{"последний", 2, true},
+		{"который 2019 года", 2, true},
+		{"тот что 1990", 1, true},
+		{"оно 2", 2, true},
+		{"оно", 0, false},
+		{"да", 0, false},
+		{"черная пантера", 0, false},
+	}
+	for _, tr := range td {
+		index, ok := SelectChoice(tr.Phrase, choices)
+		if ok != tr.Ok || (ok && index != tr.Index) {
+			t.Errorf("%s: wrong choice %d %v", tr.Phrase, index, ok)
+		}
+	}
+
+	if index, ok := SelectChoice("да", choices[:1]); !ok || index != 0 {
+		t.Error("agreement should select a single suggestion")
+	}
+}
+
+func TestSelectLocationChoice(t *testing.T) {
+	choices := []Location{
+		{City: "Москва", Subway: "Октябрьская"},
+		{City: "Новосибирск", Subway: "Октябрьская"},
+	}
+	var td = []struct {
+		Phrase string
+		Index  int
+		Ok     bool
+	}{
+		{"Новосибирск, метро Октябрьская", 1, true},
+		{"в Новосибирске", 1, true},
+		{"московская", 0, false},
+		{"в Москве", 0, true},
+		{"первая", 0, true},
+		{"последняя", 1, true},
+		{"Октябрьская", 0, false},
+		{"Москва, метро Сокол", 0, false},
+		{"та что в Москве", 0, true},
+		{"да", 0, false},
+	}
+	for _, tr := range td {
+		index, ok := SelectLocationChoice(tr.Phrase, choices)
+		if ok != tr.Ok || (ok && index != tr.Index) {
+			t.Errorf("%s: wrong choice %d %v", tr.Phrase, index, ok)
+		}
+	}
+
+	towns := []Location{
+		{City: "Кировск", Region: "Мурманская область"},
+		{City: "Кировск", Region: "Ленинградская область"},
+	}
+	if label := locationLabel(towns[1]); label != "Кировск (Ленинградская область)" {
+		t.Errorf("wrong label %s", label)
+	}
+	if index, ok := SelectLocationChoice("который в Ленинградской области", towns); !ok || index != 1 {
+		t.Error("region should select the town")
+	}
+	if _, ok := SelectLocationChoice("в Кировске", towns); ok {
+		t.Error("the same name should not select a town")
+	}
+}
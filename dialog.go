@@ -0,0 +1,117 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// DialogState is a state of the conversation with the user, it is persisted between requests
+type DialogState string
+
+const (
+	// StateOnboarding is a state of a new user who hasn't been asked anything yet
+	StateOnboarding DialogState = ""
+	// StateAwaitingLocation is a state after the user was asked about the city and subway
+	StateAwaitingLocation DialogState = "awaitingLocation"
+	// StateIdle is a state of the user with a known location waiting for a movie request
+	StateIdle DialogState = "idle"
+	// StateAwaitingChoice is a state after the user was asked which of several movies was meant
+	StateAwaitingChoice DialogState = "awaitingChoice"
+	// StateBrowsing is a state after showtimes were shown to the user
+	StateBrowsing DialogState = "browsing"
+	// StateConfirmingCinema is a state after the user was asked if a similar cinema was meant
+	StateConfirmingCinema DialogState = "confirmingCinema"
+	// StateAwaitingLocationChoice is a state after the user was asked which of several places was meant
+	StateAwaitingLocationChoice DialogState = "awaitingLocationChoice"
+	// StateConfirmingLocation is a state after the user was asked if the address was understood right
+	StateConfirmingLocation DialogState = "confirmingLocation"
+)
+
+// sessionStates live only within a single Alice session, a new session starts from idle
+var sessionStates = map[DialogState]bool{
+	StateAwaitingChoice: true,
+	StateBrowsing:       true,
+	// the offered cinema is kept in the session
+	StateConfirmingCinema: true,
+	// offered places are kept in the session
+	StateAwaitingLocationChoice: true,
+	StateConfirmingLocation:     true,
+}
+
+// Transition is a result of handling a user phrase: the next state and the answer
+type Transition struct {
+	To       DialogState
+	Response *AliceResponse
+}
+
+// DialogContext contains everything an intent needs to handle a user phrase
+type DialogContext struct {
+	Session      Session
+	Phrase       string
+	Now          time.Time
+	Current      DialogState
+	Location     *Location
+	SessionState *SessionState
+	// the device can show cards
+	Screen bool
+	// locale of the device, like ru-RU
+	Locale string
+	// set by intents that changed SessionState, so it has to be saved
+	sessionChanged bool
+}
+
+// SessionChanged marks the session state to be saved after the transition
+func (ctx *DialogContext) SessionChanged() {
+	ctx.sessionChanged = true
+}
+
+// Intent is a handler of user phrases of some kind. Intent without Matches handles any phrase.
+type Intent struct {
+	Name    string
+	Matches func(ctx *DialogContext) bool
+	Handle  func(ctx *DialogContext) Transition
+}
+
+// StateMachine keeps intents available in every dialog state
+type StateMachine struct {
+	intents map[DialogState][]Intent
+}
+
+// NewStateMachine creates a state machine without any intents
+func NewStateMachine() *StateMachine {
+	return &StateMachine{make(map[DialogState][]Intent)}
+}
+
+// On adds intents to the state, intents are checked in the order they were added
+func (m *StateMachine) On(state DialogState, intents ...Intent) {
+	m.intents[state] = append(m.intents[state], intents...)
+}
+
+// Handle finds the first matching intent of the state and runs it
+func (m *StateMachine) Handle(state DialogState, ctx *DialogContext) (Transition, bool) {
+	for _, intent := range m.intents[state] {
+		if intent.Matches != nil && !intent.Matches(ctx) {
+			continue
+		}
+		log.Printf("[INFO] User %s intent %s in state %q", ctx.Session.UserID, intent.Name, state)
+		return intent.Handle(ctx), true
+	}
+	return Transition{}, false
+}
+
+// currentState restores the state of the user, session states don't survive a new session
+func currentState(location *Location, session Session) DialogState {
+	state := location.State
+	if state == StateOnboarding && location.City != "" {
+		// users saved before states were introduced
+		return StateIdle
+	}
+	if session.New && sessionStates[state] {
+		if location.City == "" {
+			// the user has left before choosing the first location
+			return StateOnboarding
+		}
+		return StateIdle
+	}
+	return state
+}
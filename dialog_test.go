@@ -0,0 +1,247 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// dialogParser knows a single movie "Пассажир" and two movies "Оно"
+type dialogParser struct{}
+
+func (dialogParser) GetShowtimes(movieName, city, region string, date time.Time) (*SearchResult, error) {
+	switch movieName {
+	case "пассажир":
+		return dialogResult("Пассажир", date), nil
+	case "оно":
+		return nil, AmbiguousMovieError{[]Movie{
+			{Title: "Оно", Year: 2017, Provider: "stub"},
+			{Title: "Оно", Year: 1990, Provider: "stub"},
+		}}
+	}
+	return nil, NoSuchMovie
+}
+
+func (dialogParser) GetMovieShowtimes(movie Movie, city, region string, date time.Time) (*SearchResult, error) {
+	return dialogResult(movie.Title, date), nil
+}
+
+// dialogResult has a showtime in an hour today and an evening showtime on other days
+func dialogResult(movie string, date time.Time) *SearchResult {
+	showtime := time.Now().Add(time.Hour)
+	if date.After(showtime) {
+		showtime = date.Add(20 * time.Hour)
+	}
+	return &SearchResult{Movie: movie, Cinemas: []Cinema{
+		{Name: "Октябрь", Showtimes: []Showtime{{Time: showtime}}},
+	}}
+}
+
+func (dialogParser) GetMovies(city string) ([]Movie, error) {
+	return []Movie{}, nil
+}
+
+func (dialogParser) GetCinemas(city string) ([]Cinema, error) {
+	return []Cinema{{Name: "Октябрь", Link: "/msk/cinema/oktyabr"}}, nil
+}
+
+func (dialogParser) GetCinemaShowtimes(cinema Cinema, city string, date time.Time) (*CinemaSchedule, error) {
+	result := dialogResult("Пассажир", date)
+	return &CinemaSchedule{Cinema: cinema, Movies: []MovieShowtimes{
+		{Movie: result.Movie, Showtimes: result.Cinemas[0].Showtimes},
+	}}, nil
+}
+
+func dialogRequest(sessionID, phrase string, newSession bool) *AliceRequest {
+	var request AliceRequest
+	request.Meta.Timezone = "UTC"
+	request.Request.Command = phrase
+	request.Session = Session{New: newSession, SessionID: sessionID, UserID: "user"}
+	return &request
+}
+
+func TestDialogTransitions(t *testing.T) {
+	storage := NewStorage()
+	processor := NewProcessor(storage, dialogParser{}, NewMovieCatalog(dialogParser{}, time.Hour))
+
+	var td = []struct {
+		Session string
+		Phrase  string
+		New     bool
+		State   DialogState
+	}{
+		{"first", "", true, StateAwaitingLocation},
+		{"first", getAddress, false, StateAwaitingLocation},
+		{"second", "", true, StateAwaitingLocation},
+	}
+	for _, tr := range td {
+		processor.Process(dialogRequest(tr.Session, tr.Phrase, tr.New))
+		location, _ := storage.Get("user")
+		if location.State != tr.State {
+			t.Fatalf("%s: wrong state %q", tr.Phrase, location.State)
+		}
+	}
+
+	storage.Save("user", &Location{State: StateIdle, City: "Москва"})
+
+	td = []struct {
+		Session string
+		Phrase  string
+		New     bool
+		State   DialogState
+	}{
+		{"third", "", true, StateIdle},
+		{"third", "расписание пассажира", false, StateBrowsing},
+		{"third", "а еще", false, StateBrowsing},
+		{"third", "а позже", false, StateBrowsing},
+		{"third", "а завтра", false, StateBrowsing},
+		{"third", "а что есть вечером", false, StateBrowsing},
+		{"third", "что идет в октябре", false, StateBrowsing},
+		{"third", "а завтра", false, StateBrowsing},
+		// a similar cinema is confirmed first
+		{"third", "что идет в октбр", false, StateConfirmingCinema},
+		{"third", "да", false, StateBrowsing},
+		{"third", "что идет в октбр", false, StateConfirmingCinema},
+		{"third", "нет", false, StateIdle},
+		{"third", "что идет в октбр", false, StateConfirmingCinema},
+		{"third", "расписание пассажира", false, StateBrowsing},
+		{"third", "ближайший сеанс чего-нибудь", false, StateIdle},
+		{"fourth", "", true, StateIdle},
+		{"fourth", "хочу на оно", false, StateAwaitingChoice},
+		{"fourth", "второй", false, StateBrowsing},
+		{"fourth", "когда идет оно", false, StateAwaitingChoice},
+		{"fourth", "нет", false, StateIdle},
+		{"fourth", "хочу на оно", false, StateAwaitingChoice},
+		{"fourth", "расписание пассажира", false, StateBrowsing},
+		{"fourth", "сеансы фильма которого нет", false, StateIdle},
+		{"fourth", changeAddress, false, StateAwaitingLocation},
+		{"fourth", "на Октябрьской", false, StateAwaitingLocationChoice},
+		{"fourth", "в Новосибирске", false, StateIdle},
+		{"fourth", changeAddress, false, StateAwaitingLocation},
+		{"fourth", "на Октябрьской", false, StateAwaitingLocationChoice},
+		// another address instead of a choice
+		{"fourth", "Москва, метро Сокол", false, StateConfirmingLocation},
+		{"fourth", "да", false, StateIdle},
+		{"fourth", changeAddress, false, StateAwaitingLocation},
+		{"fourth", "Питер", false, StateConfirmingLocation},
+		{"fourth", "нет", false, StateAwaitingLocation},
+		{"fourth", "Абакан", false, StateConfirmingLocation},
+		// a corrected address instead of an answer
+		{"fourth", "в Москве на Соколе", false, StateConfirmingLocation},
+		{"fourth", "в москве", false, StateConfirmingLocation},
+		{"fourth", "Москва, метро Сокол", false, StateConfirmingLocation},
+		{"fourth", "да", false, StateIdle},
+	}
+	for _, tr := range td {
+		response := processor.Process(dialogRequest(tr.Session, tr.Phrase, tr.New))
+		location, _ := storage.Get("user")
+		if location.State != tr.State {
+			t.Fatalf("%s: wrong state %q, answer: %s", tr.Phrase, location.State, response.Response.Text)
+		}
+		state, _ := storage.GetSession(tr.Session)
+		if (tr.State == StateConfirmingCinema) != (state.PendingCinema != nil) {
+			t.Fatalf("%s: wrong pending cinema %v", tr.Phrase, state.PendingCinema)
+		}
+		if (tr.State == StateAwaitingChoice) != (len(state.PendingChoices) != 0) {
+			t.Fatalf("%s: wrong pending choices %v", tr.Phrase, state.PendingChoices)
+		}
+		awaitingLocation := tr.State == StateAwaitingLocationChoice || tr.State == StateConfirmingLocation
+		if awaitingLocation != (len(state.PendingLocations) != 0) {
+			t.Fatalf("%s: wrong pending locations %v", tr.Phrase, state.PendingLocations)
+		}
+	}
+	if location, _ := storage.Get("user"); location.City != "Москва" || location.Subway != "Сокол" {
+		t.Errorf("wrong saved location %v", location)
+	}
+
+	// the user has left before choosing the first location
+	storage.Save("user", &Location{State: StateAwaitingLocationChoice})
+	processor.Process(dialogRequest("fifth", "", true))
+	if location, _ := storage.Get("user"); location.State != StateAwaitingLocation {
+		t.Errorf("location should be asked again: %q", location.State)
+	}
+}
+
+// countingParser knows no movies and remembers titles it was asked about
+type countingParser struct {
+	dialogParser
+	asked []string
+}
+
+func (p *countingParser) GetShowtimes(movieName, city, region string, date time.Time) (*SearchResult, error) {
+	p.asked = append(p.asked, movieName)
+	return nil, NoSuchMovie
+}
+
+func (p *countingParser) GetMovies(city string) ([]Movie, error) {
+	return []Movie{{Title: "Черная пантера"}}, nil
+}
+
+func TestSearchCandidates(t *testing.T) {
+	parser := &countingParser{}
+	processor := NewProcessor(NewStorage(), parser, NewMovieCatalog(parser, time.Hour))
+	location := &Location{City: "Москва"}
+	if _, err := processor.searchShowtimes(Movie{Title: "черной пантеры"}, location, time.Now()); err != NoSuchMovie {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(parser.asked) != 1 || parser.asked[0] != "Черная пантера" {
+		t.Fatalf("the title of the catalog should be searched once: %v", parser.asked)
+	}
+
+	// the catalog doesn't know the movie, so other forms of the title are searched
+	parser.asked = nil
+	processor.searchShowtimes(Movie{Title: "излома времени"}, location, time.Now())
+	if len(parser.asked) != len(QueryCandidates("излома времени")) {
+		t.Fatalf("all forms should be searched: %v", parser.asked)
+	}
+
+	// without a catalog other forms of the title are searched
+	parser.asked = nil
+	processor = NewProcessor(NewStorage(), parser, nil)
+	processor.searchShowtimes(Movie{Title: "черной пантеры"}, location, time.Now())
+	if len(parser.asked) != len(QueryCandidates("черной пантеры")) {
+		t.Fatalf("all forms should be searched: %v", parser.asked)
+	}
+}
+
+// sessionlessStorage keeps locations of users but loses dialog sessions
+type sessionlessStorage struct {
+	*InMemoryStorage
+}
+
+func (sessionlessStorage) SaveSession(sessionID string, state *SessionState) error {
+	return nil
+}
+
+func TestUnsavedSessions(t *testing.T) {
+	storage := sessionlessStorage{NewStorage()}
+	processor := NewProcessor(storage, dialogParser{}, NewMovieCatalog(dialogParser{}, time.Hour))
+
+	type step struct {
+		Phrase string
+		New    bool
+		State  DialogState
+	}
+	run := func(steps []step) {
+		for _, tr := range steps {
+			response := processor.Process(dialogRequest("first", tr.Phrase, tr.New))
+			if location, _ := storage.Get("user"); location.State != tr.State {
+				t.Fatalf("%s: wrong state %q, answer: %s", tr.Phrase, location.State, response.Response.Text)
+			}
+		}
+	}
+	run([]step{
+		{"", true, StateAwaitingLocation},
+		{"Москва, метро Сокол", false, StateConfirmingLocation},
+		// the offered place is lost, so the address is asked again
+		{"да", false, StateAwaitingLocation},
+	})
+
+	storage.Save("user", &Location{City: "Москва", State: StateIdle})
+	run([]step{
+		// the offered cinema is lost, so the answer is a new request
+		{"что идет в октбр", false, StateConfirmingCinema},
+		{"да", false, StateIdle},
+		{"что идет в октбр", false, StateConfirmingCinema},
+		{"нет", false, StateIdle},
+	})
+}
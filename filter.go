@@ -0,0 +1,124 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// ShowtimeWindow is a range of time the user wants a showtime to start in
+// with the format and the price the showtime must have. Zero To and MaxPrice mean there is no upper bound.
+type ShowtimeWindow struct {
+	From     time.Time
+	To       time.Time
+	Format   FormatTag
+	MaxPrice int
+}
+
+// NewShowtimeWindow creates a window from the extracted entities.
+// Showtimes that have already started are never in the window.
+func NewShowtimeWindow(entities Entities, userTime time.Time) ShowtimeWindow {
+	from := entities.Date
+	if from.Before(userTime) {
+		from = userTime
+	}
+	if entities.From.After(from) {
+		from = entities.From
+	}
+	return ShowtimeWindow{From: from, To: entities.To, Format: entities.Format, MaxPrice: entities.MaxPrice}
+}
+
+// Contains checks if a showtime starts inside of the window
+func (w ShowtimeWindow) Contains(showtime time.Time) bool {
+	if showtime.Before(w.From) {
+		return false
+	}
+	return w.To.IsZero() || !showtime.After(w.To)
+}
+
+// Matches checks if a showtime starts inside of the window and has the required format and price
+func (w ShowtimeWindow) Matches(showtime Showtime) bool {
+	return w.Contains(showtime.Time) && w.suits(showtime)
+}
+
+// suits checks the format and the price, showtimes with unknown prices don't suit a price limit
+func (w ShowtimeWindow) suits(showtime Showtime) bool {
+	if w.MaxPrice != 0 && (showtime.MinPrice == 0 || showtime.MinPrice > w.MaxPrice) {
+		return false
+	}
+	return showtime.Tags().Has(w.Format)
+}
+
+// Filter returns cinemas with showtimes inside of the window.
+// Showtimes are sorted by start and cinemas are sorted by their first showtime.
+func (w ShowtimeWindow) Filter(searchResult *SearchResult) []Cinema {
+	return filterShowtimes(searchResult, w.Matches)
+}
+
+// Later returns cinemas with showtimes after the end of the window, showtimes that have already started are skipped
+func (w ShowtimeWindow) Later(searchResult *SearchResult) []Cinema {
+	if w.To.IsZero() {
+		return []Cinema{}
+	}
+	return filterShowtimes(searchResult, func(showtime Showtime) bool {
+		return !showtime.Time.Before(w.From) && showtime.Time.After(w.To) && w.suits(showtime)
+	})
+}
+
+// FilterSchedule returns movies of the cinema with showtimes inside of the window.
+// Movies are sorted by their first showtime.
+func (w ShowtimeWindow) FilterSchedule(schedule *CinemaSchedule) []MovieShowtimes {
+	movies := make([]MovieShowtimes, 0)
+	if schedule == nil {
+		return movies
+	}
+
+	for _, movie := range schedule.Movies {
+		sortedShowtimes := selectShowtimes(movie.Showtimes, w.Matches)
+		if len(sortedShowtimes) == 0 {
+			continue
+		}
+		movies = append(movies, MovieShowtimes{Movie: movie.Movie, Showtimes: sortedShowtimes})
+	}
+
+	sort.SliceStable(movies, func(i, j int) bool {
+		return movies[i].Showtimes[0].Time.Before(movies[j].Showtimes[0].Time)
+	})
+	return movies
+}
+
+func filterShowtimes(searchResult *SearchResult, matches func(Showtime) bool) []Cinema {
+	cinemas := make([]Cinema, 0)
+	if searchResult == nil {
+		return cinemas
+	}
+
+	for _, cinema := range searchResult.Cinemas {
+		sortedShowtimes := selectShowtimes(cinema.Showtimes, matches)
+		if len(sortedShowtimes) == 0 {
+			continue
+		}
+
+		copyCinema := cinema
+		copyCinema.Showtimes = sortedShowtimes
+		cinemas = append(cinemas, copyCinema)
+	}
+
+	sort.SliceStable(cinemas, func(i, j int) bool {
+		return cinemas[i].Showtimes[0].Time.Before(cinemas[j].Showtimes[0].Time)
+	})
+	return cinemas
+}
+
+// selectShowtimes returns matching showtimes sorted by start
+func selectShowtimes(showtimes []Showtime, matches func(Showtime) bool) []Showtime {
+	sortedShowtimes := make([]Showtime, 0)
+	for _, showtime := range showtimes {
+		if !matches(showtime) {
+			continue
+		}
+		sortedShowtimes = append(sortedShowtimes, showtime)
+	}
+
+	sort.Slice(sortedShowtimes, func(i, j int) bool { return sortedShowtimes[i].Time.Before(sortedShowtimes[j].Time) })
+	return sortedShowtimes
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestShowtimeWindow(t *testing.T) {
+	result := &SearchResult{Cinemas: []Cinema{
+		{Name: "Октябрь", Showtimes: []Showtime{{Time: testTime(23, 0)}, {Time: testTime(12, 0)}, {Time: testTime(20, 30)}}},
+		{Name: "Пионер", Showtimes: []Showtime{{Time: testTime(19, 0)}, {Time: testTime(21, 0)}}},
+		{Name: "Ролан", Showtimes: []Showtime{{Time: testTime(10, 0)}}},
+	}}
+	now := testTime(14, 0)
+
+	window := NewShowtimeWindow(Entities{Date: testTime(0, 0)}, now)
+	cinemas := window.Filter(result)
+	if len(cinemas) != 2 || cinemas[0].Name != "Пионер" || len(cinemas[1].Showtimes) != 2 {
+		t.Fatalf("past showtimes should be skipped and cinemas sorted: %+v", cinemas)
+	}
+	if !cinemas[1].Showtimes[0].Time.Equal(testTime(20, 30)) {
+		t.Fatalf("showtimes should be sorted: %+v", cinemas[1].Showtimes)
+	}
+
+	window = NewShowtimeWindow(Entities{Date: testTime(0, 0), From: testTime(20, 0), To: testTime(22, 0)}, now)
+	cinemas = window.Filter(result)
+	if len(cinemas) != 2 || cinemas[0].Name != "Октябрь" || len(cinemas[0].Showtimes) != 1 {
+		t.Fatalf("wrong showtimes in the window: %+v", cinemas)
+	}
+
+	window = NewShowtimeWindow(Entities{Date: testTime(0, 0), To: testTime(16, 0)}, now)
+	if cinemas = window.Filter(result); len(cinemas) != 0 {
+		t.Fatalf("no showtimes expected: %+v", cinemas)
+	}
+	if later := window.Later(result); len(later) != 2 {
+		t.Fatalf("later showtimes expected: %+v", later)
+	}
+
+	// "до десяти" asked in the afternoon
+	window = NewShowtimeWindow(Entities{Date: testTime(0, 0), To: testTime(10, 0)}, now)
+	later := window.Later(result)
+	if len(later) != 2 || later[1].Name != "Октябрь" || !later[1].Showtimes[0].Time.Equal(testTime(20, 30)) {
+		t.Fatalf("started showtimes should not be offered later: %+v", later)
+	}
+}
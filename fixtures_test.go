@@ -0,0 +1,31 @@
+package main
+
+import "time"
+
+// testDay is the day all showtimes in tests belong to
+var testDay = time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC)
+
+func testTime(hour, minute int) time.Time {
+	return testDay.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+}
+
+func testShowtime(hour, minute int) Showtime {
+	return Showtime{Time: testTime(hour, minute)}
+}
+
+func testTicket(hour int, url string) Showtime {
+	return Showtime{Time: testTime(hour, 0), TicketURL: url}
+}
+
+func testPriced(hour int, price string) Showtime {
+	showtime := testShowtime(hour, 0)
+	showtime.SetPrice(price)
+	return showtime
+}
+
+var testAnswers = DefaultAnswers()
+
+// testPhrases renders parts of answers with the built-in answers, they have a single variant
+func testPhrases(tag string, data AnswerData) string {
+	return testAnswers.Answer("", tag, data)
+}
@@ -0,0 +1,95 @@
+package main
+
+import "strings"
+
+// FormatTag is a set of projection and language features of a showtime
+type FormatTag int
+
+// format tags, 2D and dubbing are not tagged because they are the default
+const (
+	Format3D FormatTag = 1 << iota
+	FormatIMAX
+	Format4DX
+	FormatAtmos
+	FormatOriginal
+	FormatSubtitles
+)
+
+type formatName struct {
+	tag  FormatTag
+	name string
+}
+
+// projection formats are spoken after "в": "в IMAX 3D"
+var projectionNames = []formatName{
+	{FormatIMAX, "IMAX"},
+	{Format4DX, "4DX"},
+	{Format3D, "3D"},
+	{FormatAtmos, "Dolby Atmos"},
+}
+
+var languageNames = []formatName{
+	{FormatOriginal, "на языке оригинала"},
+	{FormatSubtitles, "с субтитрами"},
+}
+
+// words of formats as providers write them
+var providerFormats = map[string]FormatTag{
+	"3d":     Format3D,
+	"3д":     Format3D,
+	"imax":   FormatIMAX,
+	"аймакс": FormatIMAX,
+	"4dx":    Format4DX,
+	"atmos":  FormatAtmos,
+	"атмос":  FormatAtmos,
+	"orig":   FormatOriginal,
+	"eng":    FormatOriginal,
+	"sub":    FormatSubtitles,
+	"subs":   FormatSubtitles,
+}
+
+// ParseFormat finds format tags in a format string of a provider, like "IMAX 3D" or "2D, язык оригинала"
+func ParseFormat(raw string) FormatTag {
+	var tags FormatTag
+	for _, word := range splitWords(raw) {
+		switch {
+		case strings.HasPrefix(word, "оригинал") || strings.HasPrefix(word, "original"):
+			tags |= FormatOriginal
+		case strings.HasPrefix(word, "субтитр") || strings.HasPrefix(word, "subtitle"):
+			tags |= FormatSubtitles
+		default:
+			tags |= providerFormats[word]
+		}
+	}
+	return tags
+}
+
+// Tags returns format tags of the showtime
+func (s Showtime) Tags() FormatTag {
+	return ParseFormat(s.Format)
+}
+
+// Has checks if all required tags are set
+func (t FormatTag) Has(required FormatTag) bool {
+	return t&required == required
+}
+
+// Spoken describes the format for the user: "в IMAX 3D на языке оригинала"
+func (t FormatTag) Spoken() string {
+	parts := make([]string, 0)
+	projection := make([]string, 0)
+	for _, format := range projectionNames {
+		if t.Has(format.tag) {
+			projection = append(projection, format.name)
+		}
+	}
+	if len(projection) != 0 {
+		parts = append(parts, "в "+strings.Join(projection, " "))
+	}
+	for _, format := range languageNames {
+		if t.Has(format.tag) {
+			parts = append(parts, format.name)
+		}
+	}
+	return strings.Join(parts, " ")
+}
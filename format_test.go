@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFormat(t *testing.T) {
+	var td = []struct {
+		Raw    string
+		Tags   FormatTag
+		Spoken string
+	}{
+		{"2D", 0, ""},
+		{"IMAX 3D", FormatIMAX | Format3D, "в IMAX 3D"},
+		{"3D, Dolby Atmos", Format3D | FormatAtmos, "в 3D Dolby Atmos"},
+		{"2D оригинал субтитры", FormatOriginal | FormatSubtitles, "на языке оригинала с субтитрами"},
+		{"4DX 3D Original", Format4DX | Format3D | FormatOriginal, "в 4DX 3D на языке оригинала"},
+	}
+	for _, tr := range td {
+		tags := ParseFormat(tr.Raw)
+		if tags != tr.Tags {
+			t.Errorf("wrong tags of %s: %b", tr.Raw, tags)
+		}
+		if spoken := tags.Spoken(); spoken != tr.Spoken {
+			t.Errorf("wrong spoken format of %s: %s", tr.Raw, spoken)
+		}
+	}
+}
+
+func TestExtractFormat(t *testing.T) {
+	var td = []struct {
+		Phrase string
+		Tags   FormatTag
+		Rest   string
+	}{
+		{"пассажир в аймаксе", FormatIMAX, "пассажир"},
+		{"дюна в 3д на языке оригинала", Format3D | FormatOriginal, "дюна"},
+		{"а с субтитрами", FormatSubtitles, "а"},
+		{"хочу в 9 роту", 0, "хочу в 9 роту"},
+	}
+	for _, tr := range td {
+		tags, rest := ExtractFormat(tr.Phrase)
+		if tags != tr.Tags || rest != tr.Rest {
+			t.Errorf("wrong format of %s: %b %s", tr.Phrase, tags, rest)
+		}
+	}
+}
+
+func TestFilterFormat(t *testing.T) {
+	now := time.Date(2018, 3, 15, 12, 0, 0, 0, time.UTC)
+	result := &SearchResult{Movie: "Дюна", Cinemas: []Cinema{
+		{Name: "Октябрь", Showtimes: []Showtime{{Time: testTime(18, 0), Format: "2D"}, {Time: testTime(20, 0), Format: "IMAX 3D"}}},
+		{Name: "Пионер", Showtimes: []Showtime{{Time: testTime(19, 0), Format: "3D"}}},
+	}}
+
+	window := NewShowtimeWindow(Entities{Date: testDay, Format: FormatIMAX}, now)
+	cinemas := window.Filter(result)
+	if len(cinemas) != 1 || len(cinemas[0].Showtimes) != 1 || !cinemas[0].Showtimes[0].Time.Equal(testTime(20, 0)) {
+		t.Fatalf("wrong imax showtimes: %v", cinemas)
+	}
+	window = NewShowtimeWindow(Entities{Date: testDay, Format: Format3D}, now)
+	if cinemas = window.Filter(result); len(cinemas) != 2 || cinemas[0].Name != "Пионер" {
+		t.Fatalf("wrong 3d showtimes: %v", cinemas)
+	}
+	if phrase := describeCinemas(cinemas, false, testPhrases); phrase != "В Пионер фильм начинается в 19:00 в 3D. В Октябрь в 20:00 в IMAX 3D." {
+		t.Fatalf("format is not spoken: %s", phrase)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/fiam/gounidecode/unidecode"
+)
+
+// similar sounding letters of transliterated titles: vowels reduction, devoicing,
+// english and russian spelling of the same sounds
+var phoneticReplacer = strings.NewReplacer(
+	"'", "",
+	"e", "i", "y", "i", "o", "a",
+	"b", "p", "v", "f", "w", "f", "g", "k", "d", "t", "z", "s", "c", "k", "q", "k",
+	"h", "",
+)
+
+// MatchScore returns a confidence from 0 to 1 that a user query means a given title
+func MatchScore(query, title string) float64 {
+	queryWords := cleanTitle(query)
+	titleWords := cleanTitle(title)
+	if len(queryWords) == 0 || len(titleWords) == 0 {
+		return 0
+	}
+	normalizedTitle := strings.Join(titleWords, " ")
+
+	best := 0.0
+	for _, candidate := range QueryCandidates(strings.Join(queryWords, " ")) {
+		best = maxScore(best, matchNormalized(candidate, normalizedTitle))
+	}
+	return best
+}
+
+func matchNormalized(query, title string) float64 {
+	if query == title {
+		return 1
+	}
+	score := similarity(query, title)
+
+	// "пантера" for "черная пантера" is a good match, but not an exact one
+	if containsWords(title, query) {
+		score = maxScore(score, 0.85)
+	}
+
+	// "deadpool" said for "Дэдпул"
+	latinQuery, latinTitle := transliterate(query), transliterate(title)
+	score = maxScore(score, similarity(latinQuery, latinTitle)*0.95)
+
+	// misheard letters: "фарсаж" for "форсаж"
+	score = maxScore(score, similarity(phonetic(latinQuery), phonetic(latinTitle))*0.9)
+	return score
+}
+
+func transliterate(s string) string {
+	return strings.ToLower(unidecode.Unidecode(s))
+}
+
+// phonetic makes a rough sound key of a transliterated string: similar letters are merged and doubled letters are collapsed
+func phonetic(s string) string {
+	replaced := []rune(phoneticReplacer.Replace(s))
+	result := make([]rune, 0, len(replaced))
+	for i, r := range replaced {
+		if i > 0 && replaced[i-1] == r {
+			continue
+		}
+		result = append(result, r)
+	}
+	return string(result)
+}
+
+// similarity is a normalized edit distance: 1 for equal strings, 0 for completely different ones
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func maxScore(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
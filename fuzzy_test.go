@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMatchScore(t *testing.T) {
+	var td = []struct {
+		Query string
+		Title string
+		Min   float64
+		Max   float64
+	}{
+		{"пассажир", "Пассажир", 1, 1},
+		{"черной пантеры", "Черная Пантера", 1, 1},
+		{"пантера", "Черная Пантера", confidentMatch, 1},
+		{"фарсаж", "Форсаж", confidentMatch, 1},
+		{"deadpool", "Дэдпул", possibleMatch, 1},
+		{"излом времени", "Излом времени", 1, 1},
+		{"лара крофт", "Тор: Рагнарёк", 0, possibleMatch},
+	}
+	for _, tr := range td {
+		score := MatchScore(tr.Query, tr.Title)
+		if score < tr.Min || score > tr.Max {
+			t.Errorf("%s / %s: score %.2f is out of [%.2f, %.2f]", tr.Query, tr.Title, score, tr.Min, tr.Max)
+		}
+	}
+}
+
+type stubLister struct {
+	movies  []Movie
+	cinemas []Cinema
+	err     error
+	calls   int
+}
+
+func (s *stubLister) GetMovies(city string) ([]Movie, error) {
+	s.calls++
+	return s.movies, s.err
+}
+
+func (s *stubLister) GetCinemas(city string) ([]Cinema, error) {
+	return s.cinemas, nil
+}
+
+func (s *stubLister) GetCinemaShowtimes(cinema Cinema, city string, date time.Time) (*CinemaSchedule, error) {
+	return &CinemaSchedule{Cinema: cinema}, nil
+}
+
+func TestCatalogResolve(t *testing.T) {
+	lister := &stubLister{movies: []Movie{{Title: "Пассажир"}, {Title: "Черная Пантера"}, {Title: "Излом времени"}}}
+	catalog := NewMovieCatalog(lister, time.Hour)
+
+	movies, score, ok := catalog.Resolve("Москва", "черную пантеру")
+	if !ok || len(movies) != 1 || movies[0].Title != "Черная Пантера" || score < confidentMatch {
+		t.Fatalf("wrong movie resolved: %v %.2f", movies, score)
+	}
+	catalog.Resolve("москва", "пассажира")
+	if lister.calls != 1 {
+		t.Fatalf("movies should be cached per city, loaded %d times", lister.calls)
+	}
+
+	lister.movies = append(lister.movies, Movie{Title: "Розовая пантера"})
+	catalog = NewMovieCatalog(lister, time.Hour)
+	if movies, _, _ := catalog.Resolve("Москва", "пантера"); len(movies) != 2 {
+		t.Fatalf("equally good matches should be returned: %v", movies)
+	}
+}
+
+func TestCatalogFailure(t *testing.T) {
+	lister := &stubLister{err: errors.New("boom")}
+	catalog := NewMovieCatalog(lister, time.Hour)
+	catalog.Movies("Москва")
+	catalog.Movies("Москва")
+	if lister.calls != 1 {
+		t.Fatalf("a failed city should not be loaded again right away, loaded %d times", lister.calls)
+	}
+}
+
+func TestCatalogRepertoire(t *testing.T) {
+	lister := &stubLister{movies: []Movie{{Title: "Пассажир", Showtimes: 10}, {Title: "Черная Пантера", Showtimes: 50}, {Title: "Излом времени"}, {Title: "Оно", Showtimes: 10}}}
+	catalog := NewMovieCatalog(lister, time.Hour)
+
+	movies := catalog.Repertoire("Москва", 3)
+	if len(movies) != 3 || movies[0].Title != "Черная Пантера" || movies[1].Title != "Пассажир" || movies[2].Title != "Оно" {
+		t.Fatalf("wrong repertoire: %v", movies)
+	}
+	if catalog.Movies("Москва")[0].Title != "Пассажир" {
+		t.Fatalf("catalog movies should not be reordered")
+	}
+}
+
+func TestCatalogResolveCinema(t *testing.T) {
+	lister := &stubLister{cinemas: []Cinema{{Name: "Октябрь"}, {Name: "Кинотеатр Пионер"}, {Name: "Формула Кино Горизонт"}}}
+	catalog := NewMovieCatalog(lister, time.Hour)
+
+	var td = []struct {
+		Query  string
+		Cinema string
+	}{
+		{"октябре", "Октябрь"},
+		{"пионере", "Кинотеатр Пионер"},
+		{"кинотеатре пионер", "Кинотеатр Пионер"},
+		{"формуле кино горизонт", "Формула Кино Горизонт"},
+	}
+	for _, tr := range td {
+		cinema, score, ok := catalog.ResolveCinema("Москва", tr.Query)
+		if !ok || cinema.Name != tr.Cinema || score < possibleMatch {
+			t.Errorf("wrong cinema resolved for %s: %s %.2f", tr.Query, cinema.Name, score)
+		}
+	}
+	if _, score, _ := catalog.ResolveCinema("Москва", "кино"); score >= possibleMatch {
+		t.Errorf("no cinema should match a bare word")
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// gazetteerVersion is the format of gazetteer.json
+const gazetteerVersion = 1
+
+// defaultGazetteer are russian cities with their aliases and subway stations built into the skill.
+// Stations of cities with a subway map are taken from the map.
+//
+//go:embed gazetteer.json
+var defaultGazetteer []byte
+
+// case endings removed to compare inflected names: "на Соколе", "в Нижнем Новгороде".
+// Longer endings go first.
+var stemEndings = []string{
+	"ами", "ями", "ого", "его", "ому", "ему", "ыми", "ими",
+	"ая", "яя", "ое", "ее", "ые", "ие", "ый", "ий", "ой", "ей", "ую", "юю",
+	"ом", "ем", "ым", "им", "ых", "их", "ах", "ях", "ам", "ям",
+	"а", "я", "о", "е", "ы", "и", "у", "ю", "й", "ь",
+}
+
+// words around a location that don't change it: "я живу в Москве на Соколе"
+var locationFillers = map[string]bool{
+	"я": true, "мы": true, "живу": true, "живем": true, "мой": true, "адрес": true, "это": true,
+	"в": true, "во": true, "на": true, "у": true, "около": true, "возле": true, "рядом": true,
+	"с": true, "недалеко": true, "от": true, "город": true, "городе": true, "г": true,
+	"метро": true, "м": true, "станция": true, "станции": true, "ст": true,
+}
+
+type gazetteerFile struct {
+	Version int             `json:"version"`
+	Cities  []gazetteerCity `json:"cities"`
+}
+
+type gazetteerCity struct {
+	Name     string   `json:"name"`
+	Region   string   `json:"region"`
+	Aliases  []string `json:"aliases"`
+	Position *Point   `json:"position"`
+	Stations []string `json:"stations"`
+}
+
+// place is a city or a subway station with stems of all its names
+type place struct {
+	name     string
+	city     string
+	region   string
+	names    [][]string
+	position *Point
+}
+
+// Gazetteer is an offline geocoder which knows cities and subway stations by their names, aliases and inflected forms
+type Gazetteer struct {
+	cities   []place
+	stations []place
+	// strict gazetteer doesn't know phrases with words other than places
+	strict bool
+}
+
+// DefaultGazetteer returns the gazetteer built into the skill
+func DefaultGazetteer() *Gazetteer {
+	g, err := LoadGazetteer(defaultGazetteer)
+	if err != nil {
+		panic("built-in gazetteer is broken: " + err.Error())
+	}
+	var subways subwayFile
+	if err := json.Unmarshal(defaultSubways, &subways); err != nil {
+		panic("built-in subway maps are broken: " + err.Error())
+	}
+	for city, description := range subways.Cities {
+		for _, line := range description.Lines {
+			g.addStations(city, line.Stations)
+		}
+	}
+	// stations of bigger cities go first
+	sort.SliceStable(g.stations, func(i, j int) bool {
+		return g.cityIndex(g.stations[i].city) < g.cityIndex(g.stations[j].city)
+	})
+	return g
+}
+
+// LoadGazetteer loads cities from a versioned json description
+func LoadGazetteer(data []byte) (*Gazetteer, error) {
+	var file gazetteerFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Version != gazetteerVersion {
+		return nil, fmt.Errorf("unsupported gazetteer version %d", file.Version)
+	}
+	g := &Gazetteer{}
+	for _, city := range file.Cities {
+		if city.Name == "" {
+			return nil, fmt.Errorf("city without a name")
+		}
+		names := [][]string{stems(city.Name)}
+		for _, alias := range city.Aliases {
+			names = append(names, stems(alias))
+		}
+		g.cities = append(g.cities, place{name: city.Name, city: city.Name, region: city.Region, names: names, position: city.Position})
+		g.addStations(city.Name, city.Stations)
+	}
+	return g, nil
+}
+
+// addStations adds stations of the city, stations on several lines are added once
+func (g *Gazetteer) addStations(city string, stations []string) {
+	for _, station := range stations {
+		duplicate := false
+		for _, known := range g.stations {
+			if known.city == city && normalizeStation(known.name) == normalizeStation(station) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			g.stations = append(g.stations, place{name: station, city: city, names: [][]string{stems(station)}})
+		}
+	}
+}
+
+// Strict returns a gazetteer which knows only phrases made of places and words around them,
+// phrases with streets and other unknown words are left to other geocoders
+func (g *Gazetteer) Strict() *Gazetteer {
+	strict := *g
+	strict.strict = true
+	return &strict
+}
+
+// Locate finds a city and a subway station in the phrase. A station without a city is searched in all cities.
+// The same station in several cities and towns with the same name are ambiguous.
+func (g *Gazetteer) Locate(phrase string) (*Location, error) {
+	raw := splitWords(phrase)
+	words := stems(phrase)
+	used := make([]bool, len(words))
+
+	city, name, found := findPlace(g.cities, words, used, "")
+	var station place
+	var ambiguous []Location
+	if found {
+		if same := samePlaces(g.cities, name); len(same) > 1 {
+			for _, town := range same {
+				ambiguous = append(ambiguous, town.location())
+			}
+		}
+		station, _, _ = findPlace(g.stations, words, used, city.name)
+	} else if station, name, found = findPlace(g.stations, words, used, ""); found {
+		if same := samePlaces(g.stations, name); len(same) > 1 {
+			for _, other := range same {
+				ambiguous = append(ambiguous, g.stationLocation(other))
+			}
+		}
+		city, _ = g.city(station.city)
+	}
+	if !found {
+		return nil, UnknownLocationError
+	}
+	if g.strict {
+		for i, word := range raw {
+			if !used[i] && !locationFillers[word] {
+				return nil, UnknownLocationError
+			}
+		}
+	}
+	if ambiguous != nil {
+		return nil, AmbiguousLocationError{ambiguous}
+	}
+	location := city.location()
+	if station.name != "" {
+		location = g.stationLocation(station)
+	}
+	return &location, nil
+}
+
+// location of a city
+func (p place) location() Location {
+	return Location{City: p.name, Region: p.region, Position: p.position}
+}
+
+// stationLocation returns the location of the station in its city. The gazetteer doesn't know
+// positions of stations, so the position is left empty: the city centre would make
+// cinemas near the station look far.
+func (g *Gazetteer) stationLocation(station place) Location {
+	city, _ := g.city(station.city)
+	return Location{City: city.name, Region: city.region, Subway: station.name}
+}
+
+// Geocode implements Geocoder, the gazetteer doesn't know addresses
+func (g *Gazetteer) Geocode(address string) (*Point, error) {
+	return nil, UnknownLocationError
+}
+
+func (g *Gazetteer) city(name string) (place, bool) {
+	if i := g.cityIndex(name); i < len(g.cities) {
+		return g.cities[i], true
+	}
+	return place{name: name}, false
+}
+
+// cityIndex returns the position of the city in the gazetteer, unknown cities go last
+func (g *Gazetteer) cityIndex(name string) int {
+	for i, city := range g.cities {
+		if city.name == name {
+			return i
+		}
+	}
+	return len(g.cities)
+}
+
+// findPlace finds the place with the longest name among words which are not used yet and marks its words as used.
+// Empty city means places of any city.
+func findPlace(places []place, words []string, used []bool, city string) (place, []string, bool) {
+	var best place
+	var bestName []string
+	bestStart := 0
+	for _, p := range places {
+		if city != "" && p.city != city {
+			continue
+		}
+		for _, name := range p.names {
+			if len(name) <= len(bestName) {
+				continue
+			}
+			if start, ok := findWords(words, used, name); ok {
+				best, bestName, bestStart = p, name, start
+			}
+		}
+	}
+	for i := bestStart; i < bestStart+len(bestName); i++ {
+		used[i] = true
+	}
+	return best, bestName, len(bestName) > 0
+}
+
+// samePlaces returns places known by the name, bigger cities go first
+func samePlaces(places []place, name []string) []place {
+	same := make([]place, 0)
+	for _, p := range places {
+		if len(same) == maxChoices {
+			break
+		}
+		for _, known := range p.names {
+			if strings.Join(known, " ") == strings.Join(name, " ") {
+				same = append(same, p)
+				break
+			}
+		}
+	}
+	return same
+}
+
+// findWords finds a sequence of words which are not used yet
+func findWords(words []string, used []bool, sequence []string) (int, bool) {
+	for start := 0; start+len(sequence) <= len(words); start++ {
+		matches := true
+		for i, word := range sequence {
+			if used[start+i] || words[start+i] != word {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return start, true
+		}
+	}
+	return 0, false
+}
+
+// stems splits a phrase into words without case endings
+func stems(phrase string) []string {
+	words := splitWords(phrase)
+	for i, word := range words {
+		words[i] = stem(word)
+	}
+	return words
+}
+
+func stem(word string) string {
+	runes := []rune(word)
+	for _, ending := range stemEndings {
+		suffix := []rune(ending)
+		if len(runes)-len(suffix) >= minStemLength && strings.HasSuffix(word, ending) {
+			return string(runes[:len(runes)-len(suffix)])
+		}
+	}
+	return word
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGazetteerLocate(t *testing.T) {
+	gazetteer := DefaultGazetteer()
+	var td = []struct {
+		Phrase string
+		City   string
+		Subway string
+	}{
+		{"Москва, метро Сокол", "Москва", "Сокол"},
+		{"я живу в Питере", "Санкт-Петербург", ""},
+		{"на Соколе", "Москва", "Сокол"},
+		{"в Москве на Белорусской", "Москва", "Белорусская"},
+		{"Москва, Охотном ряду", "Москва", "Охотный Ряд"},
+		{"в Нижнем Новгороде на Горьковской", "Нижний Новгород", "Горьковская"},
+		{"Казань Кремлевская", "Казань", "Кремлёвская"},
+		{"спб площадь восстания", "Санкт-Петербург", "Площадь Восстания"},
+		{"Абакан", "Абакан", ""},
+		// the station of the named city wins over the bigger one
+		{"Самара, Московская", "Самара", "Московская"},
+	}
+	for _, tr := range td {
+		location, err := gazetteer.Locate(tr.Phrase)
+		if err != nil {
+			t.Errorf("%s is not located: %v", tr.Phrase, err)
+			continue
+		}
+		if location.City != tr.City || location.Subway != tr.Subway {
+			t.Errorf("wrong location of %s: %s, %s", tr.Phrase, location.City, location.Subway)
+		}
+		if (location.Position == nil) != (tr.Subway != "") {
+			t.Errorf("wrong position of %s: %v", tr.Phrase, location.Position)
+		}
+	}
+
+	if _, err := gazetteer.Locate("улица Пушкина, дом Колотушкина"); err != UnknownLocationError {
+		t.Errorf("unknown place should not be located: %v", err)
+	}
+
+	var ambiguous = []struct {
+		Phrase string
+		Labels []string
+	}{
+		{"на Октябрьской", []string{"Москва, метро Октябрьская", "Новосибирск, метро Октябрьская"}},
+		{"я живу в Кировске", []string{"Кировск (Мурманская область)", "Кировск (Ленинградская область)"}},
+	}
+	for _, tr := range ambiguous {
+		_, err := gazetteer.Locate(tr.Phrase)
+		choices, ok := err.(AmbiguousLocationError)
+		if !ok {
+			t.Errorf("%s should be ambiguous: %v", tr.Phrase, err)
+			continue
+		}
+		if labels := strings.Join(locationLabels(choices.Locations), "; "); labels != strings.Join(tr.Labels, "; ") {
+			t.Errorf("wrong choices of %s: %s", tr.Phrase, labels)
+		}
+	}
+
+	strict := gazetteer.Strict()
+	if _, err := strict.Locate("Москва, улица Тверская, 7"); err != UnknownLocationError {
+		t.Errorf("strict gazetteer should leave streets to other geocoders: %v", err)
+	}
+	if location, err := strict.Locate("я живу в Москве, метро Сокол"); err != nil || location.Subway != "Сокол" {
+		t.Errorf("strict gazetteer should know the station: %v %v", location, err)
+	}
+	if location, err := gazetteer.Locate("Москва, улица Тверская, 7"); err != nil || location.City != "Москва" {
+		t.Errorf("gazetteer should know the city: %v %v", location, err)
+	}
+}
+
+type stubGeocoder struct {
+	location *Location
+	err      error
+}
+
+func (g stubGeocoder) Locate(phrase string) (*Location, error) {
+	return g.location, g.err
+}
+
+func (g stubGeocoder) Geocode(address string) (*Point, error) {
+	if g.location == nil {
+		return nil, g.err
+	}
+	return g.location.Position, g.err
+}
+
+func TestGeocoderChain(t *testing.T) {
+	down := stubGeocoder{err: errors.New("timeout")}
+	unknown := stubGeocoder{err: UnknownLocationError}
+	known := stubGeocoder{location: &Location{City: "Москва", Position: &Point{Lat: 55.75, Lon: 37.61}}}
+
+	if location, err := (GeocoderChain{unknown, down, known}).Locate("Москва"); err != nil || location.City != "Москва" {
+		t.Fatalf("the next geocoder should be asked: %v %v", location, err)
+	}
+	if _, err := (GeocoderChain{down, unknown}).Locate("Москва"); err == nil || err == UnknownLocationError {
+		t.Fatalf("the error of the failed geocoder should be returned: %v", err)
+	}
+	if _, err := (GeocoderChain{unknown, unknown}).Geocode("Москва"); err != UnknownLocationError {
+		t.Fatalf("unknown address should stay unknown: %v", err)
+	}
+	if position, err := (GeocoderChain{down, known}).Geocode("Москва"); err != nil || position.Lat != 55.75 {
+		t.Fatalf("wrong position: %v %v", position, err)
+	}
+}
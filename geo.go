@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// mean radius of the Earth in kilometers
+const earthRadius = 6371.0
+
+// how many cinema addresses are geocoded at the same time
+const maxGeocoding = 4
+
+// Point is a geographic position
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// ParsePos parses a position of Yandex geocoder: longitude and latitude separated by a space
+func ParsePos(pos string) (*Point, bool) {
+	fields := strings.Fields(pos)
+	if len(fields) != 2 {
+		return nil, false
+	}
+	lon, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, false
+	}
+	lat, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return nil, false
+	}
+	return &Point{Lat: lat, Lon: lon}, true
+}
+
+// DistanceTo returns the great-circle distance to another point in kilometers
+func (p Point) DistanceTo(other Point) float64 {
+	lat1, lat2 := p.Lat*math.Pi/180, other.Lat*math.Pi/180
+	dLat, dLon := lat2-lat1, (other.Lon-p.Lon)*math.Pi/180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// CinemaLocator geocodes addresses of cinemas in background and remembers their positions
+type CinemaLocator struct {
+	geocode   func(address string) (*Point, error)
+	limit     chan struct{}
+	mu        sync.Mutex
+	positions map[string]*Point
+	locating  map[string]bool
+}
+
+// NewCinemaLocator creates a locator which finds positions of addresses with the geocode function
+func NewCinemaLocator(geocode func(address string) (*Point, error)) *CinemaLocator {
+	return &CinemaLocator{
+		geocode:   geocode,
+		limit:     make(chan struct{}, maxGeocoding),
+		positions: make(map[string]*Point),
+		locating:  make(map[string]bool),
+	}
+}
+
+// Locate returns cinemas with known positions. Unknown addresses are geocoded once in background,
+// their cinemas are ranked without a distance until then.
+func (l *CinemaLocator) Locate(city string, cinemas []Cinema) []Cinema {
+	if l == nil {
+		return cinemas
+	}
+	located := make([]Cinema, len(cinemas))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, cinema := range cinemas {
+		located[i] = cinema
+		if cinema.Position != nil || cinema.Address == "" {
+			continue
+		}
+		address := city + ", " + cinema.Address
+		key := strings.ToLower(city) + ", " + normalizeAddress(cinema.Address)
+		if position, ok := l.positions[key]; ok {
+			located[i].Position = position
+			continue
+		}
+		if !l.locating[key] {
+			l.locating[key] = true
+			go l.locate(key, address)
+		}
+	}
+	return located
+}
+
+func (l *CinemaLocator) locate(key, address string) {
+	l.limit <- struct{}{}
+	position, err := l.geocode(address)
+	<-l.limit
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.locating, key)
+	if err != nil && err != UnknownLocationError {
+		log.Printf("[WARN] Failed to geocode %s: %v", address, err)
+		return
+	}
+	// addresses the geocoder doesn't know are not asked again
+	l.positions[key] = position
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParsePos(t *testing.T) {
+	point, ok := ParsePos("37.587614 55.753083")
+	if !ok || point.Lat != 55.753083 || point.Lon != 37.587614 {
+		t.Fatalf("wrong point: %v", point)
+	}
+	for _, pos := range []string{"", "37.58", "37.58 north", "east 55.75"} {
+		if _, ok := ParsePos(pos); ok {
+			t.Errorf("%q should not be parsed", pos)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	moscow := Point{Lat: 55.7558, Lon: 37.6173}
+	spb := Point{Lat: 59.9343, Lon: 30.3351}
+	if distance := moscow.DistanceTo(spb); math.Abs(distance-634) > 5 {
+		t.Fatalf("wrong distance between Moscow and Saint Petersburg: %.1f", distance)
+	}
+	if distance := moscow.DistanceTo(moscow); distance != 0 {
+		t.Fatalf("wrong distance to itself: %.1f", distance)
+	}
+}
+
+func TestCinemaLocator(t *testing.T) {
+	var calls int32
+	locator := NewCinemaLocator(func(address string) (*Point, error) {
+		atomic.AddInt32(&calls, 1)
+		if address == "Москва, ул. Неизвестная, 1" {
+			return nil, UnknownLocationError
+		}
+		return &Point{Lat: 55.75, Lon: 37.58}, nil
+	})
+	cinemas := []Cinema{
+		{Name: "Октябрь", Address: "ул. Новый Арбат, 24"},
+		{Name: "Где-то", Address: "ул. Неизвестная, 1"},
+		{Name: "Без адреса"},
+	}
+
+	if located := locator.Locate("Москва", cinemas); located[0].Position != nil {
+		t.Fatal("the user must not wait for geocoding")
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		located := locator.Locate("Москва", cinemas)
+		if located[0].Position != nil {
+			if located[1].Position != nil || located[2].Position != nil {
+				t.Fatalf("unknown addresses should not have positions: %v", located)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("address was not geocoded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the same address written differently is not geocoded again
+	time.Sleep(20 * time.Millisecond)
+	located := locator.Locate("москва", []Cinema{{Name: "Октябрь", Address: "улица Новый Арбат, д. 24"}})
+	if located[0].Position == nil {
+		t.Fatal("cached position should be used")
+	}
+	if calls := atomic.LoadInt32(&calls); calls != 2 {
+		t.Fatalf("every address should be geocoded once, got %d calls", calls)
+	}
+
+	var noLocator *CinemaLocator
+	if located := noLocator.Locate("Москва", cinemas); len(located) != 3 {
+		t.Fatal("cinemas should be kept without a locator")
+	}
+}
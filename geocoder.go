@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// Geocoder finds locations of user phrases and positions of addresses
+type Geocoder interface {
+	Locate(phrase string) (*Location, error)
+	Geocode(address string) (*Point, error)
+}
+
+// AmbiguousLocationError is returned when the phrase means several places equally well:
+// the same station in several cities or towns with the same name in different regions
+type AmbiguousLocationError struct {
+	Locations []Location
+}
+
+func (err AmbiguousLocationError) Error() string {
+	return fmt.Sprintf("%d locations match the phrase", len(err.Locations))
+}
+
+// GeocoderChain asks geocoders in order until one of them knows the location.
+// Failed geocoders are skipped, the error is returned only if no one knows the location.
+// Ambiguous locations are known too, the user has to choose one of them.
+type GeocoderChain []Geocoder
+
+// Locate implements Geocoder
+func (c GeocoderChain) Locate(phrase string) (*Location, error) {
+	var lastErr error = UnknownLocationError
+	for _, geocoder := range c {
+		location, err := geocoder.Locate(phrase)
+		if _, ambiguous := err.(AmbiguousLocationError); err == nil || ambiguous {
+			return location, err
+		}
+		if err != UnknownLocationError {
+			log.Printf("[WARN] Failed to locate %s, trying the next geocoder: %v", phrase, err)
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
+
+// Geocode implements Geocoder
+func (c GeocoderChain) Geocode(address string) (*Point, error) {
+	var lastErr error = UnknownLocationError
+	for _, geocoder := range c {
+		position, err := geocoder.Geocode(address)
+		if err == nil {
+			return position, nil
+		}
+		if err != UnknownLocationError {
+			log.Printf("[WARN] Failed to geocode %s, trying the next geocoder: %v", address, err)
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
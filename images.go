@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+const dialogsImagesTemplate = "https://dialogs.yandex.net/api/v1/skills/%s/images"
+
+// ImageStore provides ids of images uploaded to Yandex Dialogs, cards show only uploaded images
+type ImageStore interface {
+	ImageID(url string) (string, bool)
+}
+
+// DialogsImageStore uploads images by URL in background and remembers their ids
+type DialogsImageStore struct {
+	skillID   string
+	token     string
+	mu        sync.Mutex
+	ids       map[string]string
+	uploading map[string]bool
+}
+
+// NewDialogsImageStore creates a store for the skill authorized with an OAuth token
+func NewDialogsImageStore(skillID, token string) *DialogsImageStore {
+	return &DialogsImageStore{
+		skillID:   skillID,
+		token:     token,
+		ids:       make(map[string]string),
+		uploading: make(map[string]bool),
+	}
+}
+
+// ImageID returns the id of an uploaded image. An unknown image is uploaded in background
+// and the card is sent without it this time.
+func (s *DialogsImageStore) ImageID(url string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.ids[url]; ok {
+		return id, true
+	}
+	if !s.uploading[url] {
+		s.uploading[url] = true
+		go s.upload(url)
+	}
+	return "", false
+}
+
+func (s *DialogsImageStore) upload(url string) {
+	id, err := s.post(url)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.uploading, url)
+	if err != nil {
+		log.Printf("[WARN] Failed to upload image %s: %v", url, err)
+		return
+	}
+	s.ids[url] = id
+}
+
+func (s *DialogsImageStore) post(url string) (string, error) {
+	body, err := json.Marshal(map[string]string{"url": url})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", fmt.Sprintf(dialogsImagesTemplate, s.skillID), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "OAuth "+s.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var uploaded struct {
+		Image struct {
+			ID string `json:"id"`
+		} `json:"image"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&uploaded); err != nil {
+		return "", err
+	}
+	return uploaded.Image.ID, nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -14,17 +15,21 @@ import (
 )
 
 const searchURLTemplate = "https://www.kinopoisk.ru/index.php?kp_query=%s"
-const showtimeURLTemplate = "https://kinopoisk.ru%s?search=%s"
+const showtimeURLTemplate = "https://kinopoisk.ru%s?search=%s&date=%s"
+const ticketURLTemplate = "https://widget.tickets.yandex.ru/w/sessions/%s"
+
+// KinopoiskParser is a ShowtimeParser backed by kinopoisk.ru
+type KinopoiskParser struct{}
 
 // GetShowtimes returns a search result from kinopoisk.ru based on movie name and a user location
-func GetShowtimes(movieName, city, region string) (*SearchResult, error) {
-	name, link, err := findMovieInfo(movieName)
+func (KinopoiskParser) GetShowtimes(movieName, city, region string, date time.Time) (*SearchResult, error) {
+	name, link, poster, err := findMovieInfo(movieName)
 	if err != nil {
 		return nil, err
 	}
 	// find a movie schedule
-	showtimeRedirectLink := fmt.Sprintf(showtimeURLTemplate, link, url.QueryEscape(region))
-	cinemas, err := findSchedule(showtimeRedirectLink)
+	showtimeRedirectLink := fmt.Sprintf(showtimeURLTemplate, link, url.QueryEscape(region), date.Format("2006-01-02"))
+	cinemas, err := findSchedule(showtimeRedirectLink, date)
 	if err != nil {
 		return nil, err
 	}
@@ -32,29 +37,31 @@ func GetShowtimes(movieName, city, region string) (*SearchResult, error) {
 	return &SearchResult{
 		Movie:   name,
 		Cinemas: cinemas,
+		Poster:  poster,
 	}, nil
 }
 
-func findMovieInfo(movieName string) (string, string, error) {
+// findMovieInfo returns the name, the schedule link and the poster of the best matching movie
+func findMovieInfo(movieName string) (string, string, string, error) {
 	resp, err := getWithProxy(fmt.Sprintf(searchURLTemplate, url.QueryEscape(movieName)))
 	if err != nil {
-		return "", "", err
+		return "", "", "", err
 	}
 
 	decodedHTML, err := decodeWindows(resp)
 	if err != nil {
-		return "", "", err
+		return "", "", "", err
 	}
 
 	movieRoot := soup.HTMLParse(decodedHTML)
 	searchResults := movieRoot.Find("div", "class", "search_results")
 	if searchResults.Error != nil {
-		return "", "", fmt.Errorf("failed to find a search results block")
+		return "", "", "", NoSuchMovieError{movieName}
 	}
 	// find matching movie
 	topResult := searchResults.Find("div", "class", "element most_wanted")
 	if topResult.Error != nil {
-		return "", "", fmt.Errorf("movie with such name does not exists: %s", movieName)
+		return "", "", "", NoSuchMovieError{movieName}
 	}
 
 	// change to timezone based on region/city
@@ -65,8 +72,10 @@ func findMovieInfo(movieName string) (string, string, error) {
 	name := infoBlock.Find("a").Text()
 	year := infoBlock.Find("span", "class", "year").Text()
 
+	// old movies and movies without a schedule are not showing, other providers may know them
 	if isNotOutdated(year, currentTime.Year()) {
-		return "", "", fmt.Errorf("movie %s is too old and no possible showtimes will be found: %s", name, year)
+		log.Printf("[INFO] Movie %s of %s is too old to have showtimes", name, year)
+		return "", "", "", NoSuchMovieError{movieName}
 	}
 
 	// find link to the schedule
@@ -87,13 +96,23 @@ func findMovieInfo(movieName string) (string, string, error) {
 		}
 	}
 	if link == "" {
-		return "", "", fmt.Errorf("failed to find a link to the schedule for movie: %s", name)
+		log.Printf("[INFO] Movie %s has no schedule", name)
+		return "", "", "", NoSuchMovieError{movieName}
 	}
 
-	return name, link, nil
+	var poster string
+	if image := topResult.Find("div", "class", "pic").Find("img"); image.Error == nil {
+		// posters are loaded lazily, the real address is in the title
+		poster = image.Attrs()["title"]
+		if poster == "" {
+			poster = image.Attrs()["src"]
+		}
+	}
+
+	return name, link, poster, nil
 }
 
-func findSchedule(redirectLink string) ([]Cinema, error) {
+func findSchedule(redirectLink string, date time.Time) ([]Cinema, error) {
 	showtimeRaw, err := getWithProxy(redirectLink)
 	if err != nil {
 		return nil, err
@@ -134,14 +153,19 @@ func findSchedule(redirectLink string) ([]Cinema, error) {
 			format := formatsRow.Find("span", "class", "schedule-item__formats-format").Text()
 
 			for _, scheduleItem := range formatsRow.FindAll("span", "class", "schedule-item__session-button-wrapper") {
-				rawTime := scheduleItem.Find("span", "class", "schedule-item__session-button schedule-item__session-button_active js-yaticket-button").Text()
+				sessionButton := scheduleItem.Find("span", "class", "schedule-item__session-button schedule-item__session-button_active js-yaticket-button")
+				rawTime := sessionButton.Text()
 				price := scheduleItem.Find("span", "class", "schedule-item__price").Text()
-				if time, err := time.Parse("15:04", rawTime); err == nil {
-					showtimes = append(showtimes, Showtime{
+				if time, err := showtimeAt(date, rawTime); err == nil {
+					showtime := Showtime{
 						Time:   time,
-						Price:  price,
 						Format: format,
-					})
+					}
+					showtime.SetPrice(price)
+					if sessionID := sessionButton.Attrs()["data-session-id"]; sessionID != "" {
+						showtime.TicketURL = fmt.Sprintf(ticketURLTemplate, url.PathEscape(sessionID))
+					}
+					showtimes = append(showtimes, showtime)
 				}
 			}
 
@@ -159,15 +183,12 @@ func findSchedule(redirectLink string) ([]Cinema, error) {
 func getWithProxy(siteURL string) (string, error) {
 	requestURL := "https://api.proxycrawl.com/?token=&url=" + siteURL
 
-	httpClient := &http.Client{}
-
 	req, err := http.NewRequest("GET", requestURL, nil)
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/64.0.3282.186 Safari/537.36")
 
-	fmt.Println(siteURL)
 	resp, err := httpClient.Do(req)
 	if err != nil {
 		return "", err
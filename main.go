@@ -2,8 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -20,6 +23,10 @@ type AliceRequest struct {
 		Locale   string `json:"locale"`
 		Timezone string `json:"timezone"`
 		ClientID string `json:"client_id"`
+		// screen is present only for devices which can show cards
+		Interfaces struct {
+			Screen *struct{} `json:"screen,omitempty"`
+		} `json:"interfaces"`
 	} `json:"meta"`
 	Request struct {
 		Type   string `json:"type"`
@@ -41,12 +48,40 @@ type Button struct {
 	Hide  bool   `json:"hide"`
 }
 
+// Card is a rich answer for devices with a screen: a BigImage or an ItemsList
+type Card struct {
+	Type        string      `json:"type"`
+	ImageID     string      `json:"image_id,omitempty"`
+	Title       string      `json:"title,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Button      *CardButton `json:"button,omitempty"`
+	Header      *CardHeader `json:"header,omitempty"`
+	Items       []CardItem  `json:"items,omitempty"`
+}
+
+type CardHeader struct {
+	Text string `json:"text"`
+}
+
+type CardItem struct {
+	ImageID     string      `json:"image_id,omitempty"`
+	Title       string      `json:"title"`
+	Description string      `json:"description,omitempty"`
+	Button      *CardButton `json:"button,omitempty"`
+}
+
+type CardButton struct {
+	Text string `json:"text,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
 type AliceResponse struct {
 	Version  string  `json:"version"`
 	Session  Session `json:"session"`
 	Response struct {
 		Text       string   `json:"text"`
 		Tts        string   `json:"tts"`
+		Card       *Card    `json:"card,omitempty"`
 		Buttons    []Button `json:"buttons"`
 		EndSession bool     `json:"end_session"`
 	} `json:"response"`
@@ -55,9 +90,41 @@ type AliceResponse struct {
 func main() {
 	dynamoStorage, err := NewDynamoStorage()
 	if err != nil {
-		log.Fatal("[ERROR] Failed to init a dynamostorage: %v", err)
+		log.Fatalf("[ERROR] Failed to init a dynamostorage: %v", err)
+	}
+	registry, err := newProviderRegistry(os.Getenv("SHOWTIME_PROVIDERS"))
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to init showtime providers: %v", err)
+	}
+	deadline, err := time.ParseDuration(getEnv("SHOWTIME_DEADLINE", defaultDeadline))
+	if err != nil {
+		log.Fatalf("[ERROR] Wrong showtime providers deadline: %v", err)
+	}
+	registry.SetDeadline(deadline)
+	log.Printf("[INFO] Showtime providers: %s, deadline: %v", strings.Join(registry.Names(), ", "), deadline)
+	catalog := NewMovieCatalog(registry, catalogRefresh)
+	go catalog.Run()
+	processor := NewProcessor(dynamoStorage, registry, catalog)
+	geocoderTimeout, err := time.ParseDuration(getEnv("GEOCODER_TIMEOUT", defaultGeocoderTimeout))
+	if err != nil {
+		log.Fatalf("[ERROR] Wrong geocoder timeout: %v", err)
+	}
+	gazetteer := DefaultGazetteer()
+	// known cities and stations are located offline, the gazetteer also answers when Yandex is down
+	geocoder := GeocoderChain{gazetteer.Strict(), NewYandexGeocoder(os.Getenv("YANDEX_GEOCODER_KEY"), geocoderTimeout), gazetteer}
+	processor.SetGeocoder(geocoder)
+	processor.SetCinemaLocator(NewCinemaLocator(geocoder.Geocode))
+	if skillID, token := os.Getenv("SKILL_ID"), os.Getenv("DIALOGS_TOKEN"); skillID != "" && token != "" {
+		processor.SetImageStore(NewDialogsImageStore(skillID, token))
+	}
+	if path := os.Getenv("ANSWERS_FILE"); path != "" {
+		answers, err := LoadAnswers(path, answerTags)
+		if err != nil {
+			log.Fatalf("[ERROR] Failed to load answers: %v", err)
+		}
+		processor.SetAnswers(answers)
+		go answers.Watch(answersReload)
 	}
-	processor := NewProcessor(dynamoStorage)
 	http.HandleFunc("/dialog", handler(processor))
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("healthy"))
@@ -66,6 +133,47 @@ func main() {
 	log.Fatal(http.ListenAndServe(":5000", nil))
 }
 
+// kinopoisk is loaded through a proxy which needs a token, so it has to be enabled explicitly
+const defaultProviders = "rambler"
+
+// how often movies currently in distribution are reloaded
+const catalogRefresh = 6 * time.Hour
+
+// providers are asked concurrently and merged, zero deadline means a sequential fallback chain
+const defaultDeadline = "5s"
+
+// Alice waits for an answer only a few seconds, a slow geocoder is replaced by the gazetteer
+const defaultGeocoderTimeout = "1500ms"
+
+func getEnv(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// newProviderRegistry registers providers from a comma separated list, the first one has the highest priority
+func newProviderRegistry(config string) (*ProviderRegistry, error) {
+	if config == "" {
+		config = defaultProviders
+	}
+	available := map[string]ShowtimeParser{
+		"rambler":   RamblerParser{},
+		"kinopoisk": KinopoiskParser{},
+	}
+
+	registry := NewProviderRegistry()
+	for priority, name := range strings.Split(config, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		parser, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown showtime provider: %s", name)
+		}
+		registry.Register(name, priority, parser)
+	}
+	return registry, nil
+}
+
 func handler(processor *MessageProcessor) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
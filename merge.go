@@ -0,0 +1,166 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// words that cinemas use inconsistently in their names across providers
+var cinemaNameNoise = map[string]bool{
+	"кинотеатр":    true,
+	"кинотеатре":   true,
+	"кинотеатра":   true,
+	"кино":         true,
+	"кинокомплекс": true,
+	"киноцентр":    true,
+	"кинозал":      true,
+	"тц":           true,
+	"трц":          true,
+}
+
+// address words that are abbreviated differently by providers, like "улица" and "ул."
+var addressNoise = map[string]bool{
+	"г": true, "город": true,
+	"ул": true, "улица": true,
+	"пр": true, "т": true, "проспект": true,
+	"пер": true, "переулок": true,
+	"ш": true, "шоссе": true,
+	"пл": true, "площадь": true,
+	"б": true, "р": true, "бульвар": true,
+	"д": true, "дом": true,
+	"стр": true, "строение": true,
+	"к": true, "корп": true, "корпус": true,
+}
+
+// MergeResults merges search results from several providers into one.
+// Results are expected in priority order: the first one wins when providers disagree.
+func MergeResults(results []*SearchResult) *SearchResult {
+	var merged *SearchResult
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &SearchResult{Movie: result.Movie, Year: result.Year, Cinemas: make([]Cinema, 0)}
+		}
+		if merged.Poster == "" {
+			merged.Poster = result.Poster
+		}
+		for _, cinema := range result.Cinemas {
+			merged.Cinemas = mergeCinema(merged.Cinemas, cinema)
+		}
+	}
+	return merged
+}
+
+func mergeCinema(cinemas []Cinema, cinema Cinema) []Cinema {
+	for i := range cinemas {
+		if !isSameCinema(cinemas[i], cinema) {
+			continue
+		}
+		if cinemas[i].Address == "" {
+			cinemas[i].Address = cinema.Address
+		}
+		if cinemas[i].Subway == "" {
+			cinemas[i].Subway = cinema.Subway
+		}
+		if cinemas[i].Position == nil {
+			cinemas[i].Position = cinema.Position
+		}
+		cinemas[i].Showtimes = mergeShowtimes(cinemas[i].Showtimes, cinema.Showtimes)
+		return cinemas
+	}
+	copyCinema := cinema
+	copyCinema.Showtimes = append([]Showtime{}, cinema.Showtimes...)
+	return append(cinemas, copyCinema)
+}
+
+// mergeShowtimes adds showtimes of another provider, a showtime known already is completed with its details.
+// Showtimes of one provider at the same time are different halls, so they are never merged with each other.
+func mergeShowtimes(showtimes, other []Showtime) []Showtime {
+	merged := make([]Showtime, 0, len(showtimes)+len(other))
+	merged = append(merged, showtimes...)
+	matched := make([]bool, len(showtimes))
+
+	for _, showtime := range other {
+		duplicate := false
+		for i := range showtimes {
+			if matched[i] || !merged[i].Time.Equal(showtime.Time) {
+				continue
+			}
+			if merged[i].Format != "" && showtime.Format != "" && merged[i].Tags() != showtime.Tags() {
+				continue
+			}
+			if merged[i].Format == "" {
+				merged[i].Format = showtime.Format
+			}
+			if merged[i].TicketURL == "" {
+				merged[i].TicketURL = showtime.TicketURL
+			}
+			if merged[i].MinPrice == 0 {
+				merged[i].Price = showtime.Price
+				merged[i].MinPrice, merged[i].MaxPrice = showtime.MinPrice, showtime.MaxPrice
+			}
+			matched[i] = true
+			duplicate = true
+			break
+		}
+		if !duplicate {
+			merged = append(merged, showtime)
+		}
+	}
+	return merged
+}
+
+// isSameCinema checks if two cinemas from different providers are the same place
+func isSameCinema(a, b Cinema) bool {
+	nameA, nameB := normalizeCinemaName(a.Name), normalizeCinemaName(b.Name)
+	addressA, addressB := normalizeAddress(a.Address), normalizeAddress(b.Address)
+
+	if nameA != "" && nameA == nameB {
+		return addressA == "" || addressB == "" || containsWords(addressA, addressB) || containsWords(addressB, addressA)
+	}
+	if addressA != "" && addressA == addressB {
+		return true
+	}
+	if nameA == "" || nameB == "" {
+		return false
+	}
+	// "Каро 11 Октябрь" and "Октябрь" in the same building
+	if containsWords(nameA, nameB) || containsWords(nameB, nameA) {
+		return addressA == "" || addressB == "" || containsWords(addressA, addressB) || containsWords(addressB, addressA)
+	}
+	return false
+}
+
+// containsWords checks that sub is found in s as a sequence of whole words
+func containsWords(s, sub string) bool {
+	return strings.Contains(" "+s+" ", " "+sub+" ")
+}
+
+func normalizeCinemaName(name string) string {
+	return joinWithout(splitWords(name), cinemaNameNoise)
+}
+
+func normalizeAddress(address string) string {
+	return joinWithout(splitWords(address), addressNoise)
+}
+
+func joinWithout(words []string, noise map[string]bool) string {
+	result := make([]string, 0, len(words))
+	for _, word := range words {
+		if noise[word] {
+			continue
+		}
+		result = append(result, word)
+	}
+	return strings.Join(result, " ")
+}
+
+// splitWords lowercases a string, replaces ё and splits it by everything that is not a letter or a digit
+func splitWords(s string) []string {
+	s = strings.Replace(strings.ToLower(s), "ё", "е", -1)
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
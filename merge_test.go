@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestSameCinema(t *testing.T) {
+	var td = []struct {
+		A, B Cinema
+		Same bool
+	}{
+		{Cinema{Name: "Кинотеатр «Октябрь»"}, Cinema{Name: "Октябрь"}, true},
+		{Cinema{Name: "Каро 11 Октябрь", Address: "ул. Новый Арбат, 24"}, Cinema{Name: "Октябрь", Address: "улица Новый Арбат, д. 24"}, true},
+		{Cinema{Name: "Пионер", Address: "Кутузовский проспект, 21"}, Cinema{Name: "Пионер", Address: "Кутузовский пр-т, 21"}, true},
+		{Cinema{Name: "Синема Парк", Address: "ул. Ленина, 1"}, Cinema{Name: "Синема Парк", Address: "ул. Мира, 5"}, false},
+		{Cinema{Name: "Ролан"}, Cinema{Name: "Пионер"}, false},
+	}
+	for _, tr := range td {
+		if isSameCinema(tr.A, tr.B) != tr.Same {
+			t.Errorf("%s / %s: expected same=%v", tr.A.Name, tr.B.Name, tr.Same)
+		}
+	}
+}
+
+func TestMergeResults(t *testing.T) {
+	rambler := &SearchResult{Movie: "Пассажир", Cinemas: []Cinema{
+		{Name: "Октябрь", Showtimes: []Showtime{{Time: testTime(19, 0)}, {Time: testTime(21, 0)}}},
+	}}
+	kinopoisk := &SearchResult{Movie: "Пассажир (2018)", Cinemas: []Cinema{
+		{Name: "Кинотеатр Октябрь", Address: "ул. Новый Арбат, 24", Showtimes: []Showtime{{Time: testTime(21, 0), Format: "3D"}, {Time: testTime(23, 0)}}},
+		// two halls at the same time
+		{Name: "Пионер", Showtimes: []Showtime{{Time: testTime(20, 0)}, {Time: testTime(20, 0)}}},
+	}}
+
+	merged := MergeResults([]*SearchResult{rambler, nil, kinopoisk})
+	if merged.Movie != "Пассажир" {
+		t.Fatalf("movie name should come from the first provider: %s", merged.Movie)
+	}
+	if len(merged.Cinemas) != 2 {
+		t.Fatalf("expected 2 cinemas, got %d", len(merged.Cinemas))
+	}
+	october := merged.Cinemas[0]
+	if october.Address == "" || len(october.Showtimes) != 3 {
+		t.Fatalf("wrong merged cinema: %+v", october)
+	}
+	if october.Showtimes[1].Format != "3D" {
+		t.Fatalf("format should be taken from the duplicate showtime: %+v", october.Showtimes[1])
+	}
+	if len(merged.Cinemas[1].Showtimes) != 2 {
+		t.Fatalf("showtimes of one provider should not be merged: %+v", merged.Cinemas[1])
+	}
+	if MergeResults([]*SearchResult{nil, nil}) != nil {
+		t.Fatal("nothing to merge should produce nil")
+	}
+}
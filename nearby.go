@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how many cinemas near the user are loaded to find the soonest showtimes
+const maxNearbyCinemas = 5
+
+// how many showtimes are spoken when the user asks for anything starting soon
+const soonestCount = 3
+
+// showtimes starting sooner are spoken as "через 20 минут"
+const soonThreshold = time.Hour
+
+// Screening is a showtime of a movie in a cinema
+type Screening struct {
+	Movie    string
+	Cinema   string
+	Showtime Showtime
+}
+
+// nearbyCinemas selects cinemas the user gets to faster by subway.
+// Without a station all cinemas of the city are near.
+func nearbyCinemas(cinemas []Cinema, travel *Travel) []Cinema {
+	nearby := travel.Rank(cinemas)
+	if len(nearby) > maxNearbyCinemas {
+		nearby = nearby[:maxNearbyCinemas]
+	}
+	return nearby
+}
+
+// loadSchedules loads schedules of the cinemas in parallel, failed cinemas are skipped
+func loadSchedules(parser CinemaParser, cinemas []Cinema, city string, date time.Time) []*CinemaSchedule {
+	schedules := make([]*CinemaSchedule, len(cinemas))
+	var wg sync.WaitGroup
+	for i, cinema := range cinemas {
+		wg.Add(1)
+		go func(i int, cinema Cinema) {
+			defer wg.Done()
+			schedule, err := parser.GetCinemaShowtimes(cinema, city, date)
+			if err != nil {
+				log.Printf("[WARN] Failed to load schedule of %s: %v", cinema.Name, err)
+				return
+			}
+			schedules[i] = schedule
+		}(i, cinema)
+	}
+	wg.Wait()
+	return schedules
+}
+
+// soonestScreenings returns the first showtimes inside of the window, every movie is mentioned once
+func soonestScreenings(schedules []*CinemaSchedule, window ShowtimeWindow, count int) []Screening {
+	screenings := make([]Screening, 0)
+	for _, schedule := range schedules {
+		for _, movie := range window.FilterSchedule(schedule) {
+			screenings = append(screenings, Screening{movie.Movie, schedule.Cinema.Name, movie.Showtimes[0]})
+		}
+	}
+	sort.SliceStable(screenings, func(i, j int) bool {
+		return screenings[i].Showtime.Time.Before(screenings[j].Showtime.Time)
+	})
+
+	soonest := make([]Screening, 0, count)
+	seen := make(map[string]bool)
+	for _, screening := range screenings {
+		if len(soonest) == count {
+			break
+		}
+		key := strings.Join(cleanTitle(screening.Movie), " ")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		soonest = append(soonest, screening)
+	}
+	return soonest
+}
+
+// describeScreenings speaks showtimes starting soon relatively to the user's time
+func describeScreenings(screenings []Screening, userTime time.Time, phrases Phrases) string {
+	sentences := make([]string, 0, len(screenings))
+	for _, screening := range screenings {
+		data := AnswerData{Movie: screening.Movie, Cinema: screening.Cinema}
+		minutes := int(screening.Showtime.Time.Sub(userTime).Minutes())
+		switch {
+		case minutes < 1:
+			data.Format = screening.Showtime.Tags().Spoken()
+			sentences = append(sentences, phrases("SCREENING_NOW", data))
+		case time.Duration(minutes)*time.Minute < soonThreshold:
+			data.Count = minutes
+			data.Format = screening.Showtime.Tags().Spoken()
+			sentences = append(sentences, phrases("SCREENING_SOON", data))
+		default:
+			data.Time = describeShowtime(screening.Showtime)
+			sentences = append(sentences, phrases("SCREENING_AT", data))
+		}
+	}
+	return strings.Join(sentences, " ")
+}
+
+// plural chooses a russian word form for a number: 1 минуту, 2 минуты, 5 минут
+func plural(n int, one, few, many string) string {
+	if n%100 >= 11 && n%100 <= 14 {
+		return many
+	}
+	switch n % 10 {
+	case 1:
+		return one
+	case 2, 3, 4:
+		return few
+	}
+	return many
+}
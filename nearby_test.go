@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSoonestScreenings(t *testing.T) {
+	now := time.Date(2018, 3, 15, 18, 40, 0, 0, time.UTC)
+	cinemas := []Cinema{
+		{Name: "Пионер", Subway: "м. Кутузовская"},
+		{Name: "Октябрь", Subway: "Арбатская, Смоленская"},
+		{Name: "Художественный", Subway: "Арбатская"},
+	}
+	subways := DefaultSubways()
+	nearby := nearbyCinemas(cinemas, subways.Travel(&Location{City: "Москва", Subway: "Арбатская"}))
+	if len(nearby) != 3 || nearby[0].Name != "Октябрь" || nearby[2].Name != "Пионер" {
+		t.Fatalf("wrong nearby cinemas: %v", nearby)
+	}
+	if all := nearbyCinemas(cinemas, subways.Travel(&Location{City: "Москва"})); len(all) != 3 || all[0].Name != "Пионер" {
+		t.Fatalf("all cinemas are near without a subway: %v", all)
+	}
+
+	schedules := []*CinemaSchedule{
+		{Cinema: nearby[0], Movies: []MovieShowtimes{
+			{Movie: "Пассажир", Showtimes: []Showtime{testShowtime(18, 0), testShowtime(21, 0)}},
+			{Movie: "Дюна", Showtimes: []Showtime{testShowtime(19, 0)}},
+		}},
+		nil,
+		{Cinema: nearby[1], Movies: []MovieShowtimes{
+			{Movie: "Дюна", Showtimes: []Showtime{testShowtime(19, 30)}},
+			{Movie: "Оно", Showtimes: []Showtime{testShowtime(20, 15)}},
+		}},
+	}
+	screenings := soonestScreenings(schedules, NewShowtimeWindow(Entities{Date: testShowtime(0, 0).Time}, now), soonestCount)
+	if len(screenings) != 3 || screenings[0].Movie != "Дюна" || screenings[1].Movie != "Оно" || screenings[2].Movie != "Пассажир" {
+		t.Fatalf("wrong soonest screenings: %v", screenings)
+	}
+
+	expected := "Через 20 минут в Октябрь начинается «Дюна». В 20:15 в Художественный начинается «Оно». В 21:00 в Октябрь начинается «Пассажир»."
+	if phrase := describeScreenings(screenings, now, testPhrases); phrase != expected {
+		t.Fatalf("wrong phrase: %s", phrase)
+	}
+	starting := describeScreenings(screenings[:1], testTime(19, 0), testPhrases)
+	if starting != "Прямо сейчас в Октябрь начинается «Дюна»." {
+		t.Fatalf("wrong phrase for a starting showtime: %s", starting)
+	}
+}
+
+func TestPlural(t *testing.T) {
+	for n, expected := range map[int]string{1: "минуту", 3: "минуты", 5: "минут", 11: "минут", 21: "минуту", 44: "минуты"} {
+		if form := plural(n, "минуту", "минуты", "минут"); form != expected {
+			t.Errorf("wrong form for %d: %s", n, form)
+		}
+	}
+}
@@ -1,10 +1,9 @@
 package main
 
 import (
-	"log"
-	"math/rand"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -68,279 +67,576 @@ func Default() *Template {
 	return t
 }
 
-const changeAddress = "Сменить адрес"
-const getAddress = "Мой адрес"
+// SoonestTemplate creates a template for requests of any movie starting soon near the user
+func SoonestTemplate() *Template {
+	t, _ := New(
+		`^(?:а )?(?:ближайший сеанс|ближайшие сеансы)(?: чего-нибудь| чего нибудь| любого фильма| хоть чего-нибудь| рядом| поблизости)*$`,
+		`^(?:а )?(?:что|какой фильм|какое кино)(?: скоро)? (?:начинается|начнется)(?: скоро| сейчас| в ближайшее время| рядом| поблизости)*$`,
+		`^(?:а )?(?:на что|куда)(?: я)? (?:можно )?(?:успею|успеть|успеваю)(?: сейчас| рядом| поблизости)*$`,
+		`^(?:а )?(?:что|какое кино)(?: можно)? (?:идет |посмотреть |показывают )?(?:рядом|поблизости|прямо сейчас|сейчас рядом|рядом сейчас)$`,
+	)
+	return t
+}
 
-// MessageProcessor processes user phrases from Alice skill
-type MessageProcessor struct {
-	storage  LocationStorage
-	template *Template
-	answers  map[string][]string
+// RepertoireTemplate creates a template for requests of movies showing in the city
+func RepertoireTemplate() *Template {
+	t, _ := New(
+		`^(?:а |ну )?(?:что|какие фильмы|какое кино)(?: сейчас| сегодня| нового| интересного)?(?: идет| идут| показывают| есть| можно посмотреть)? (?:в кино|в кинотеатрах|в прокате|на экранах)$`,
+		`^(?:а |ну )?(?:что|какие фильмы)(?: сейчас| сегодня)? (?:идет|идут|показывают|в прокате)$`,
+		`^(?:а |ну )?(?:что|какой фильм|какое кино)(?: бы)? (?:посмотреть|посоветуешь|посоветуй|глянуть)$`,
+		`^(?:афиша|репертуар|что в кино)$`,
+	)
+	return t
 }
 
-// NewProcessor creates a new MessageProcessor with default templates
-func NewProcessor(storage LocationStorage) *MessageProcessor {
-	return &MessageProcessor{storage, Default(), availableAnswers()}
+// CinemaTemplate creates a template for requests of movies showing in a cinema
+func CinemaTemplate() *Template {
+	t, _ := New(
+		`^(?:а )?(?:что|какие фильмы|какое кино|какие сеансы)(?: сейчас)?(?: идет| идут| показывают| есть| будет| будут| можно посмотреть)? (?:в|во) (?:кинотеатре |кинотеатр )(?P<cinema>.+)$`,
+		`^(?:а )?(?:что|какие фильмы|какое кино|какие сеансы)(?: сейчас)?(?: идет| идут| показывают| есть| будет| будут| можно посмотреть)? (?:в|во) (?P<cinema>.+)$`,
+		`^(?:расписание|афиша|сеансы|репертуар)(?: в)? (?:кинотеатра|кинотеатре|кинотеатр) (?P<cinema>.+)$`,
+	)
+	return t
 }
 
-// Process processes through state machine logic an retrieves intents from user's phrases
-func (p *MessageProcessor) Process(aliceRequest *AliceRequest) *AliceResponse {
-	userID := aliceRequest.Session.UserID
-	timezone, _ := time.LoadLocation(aliceRequest.Meta.Timezone)
+// More creates a template for requests to continue the list of cinemas
+func More() *Template {
+	t, _ := New(
+		`^(?:а |и |покажи |назови |давай |да )*(?:еще|дальше|следующие|другие)(?: кинотеатры| кинотеатров| варианты| сеансы)?$`,
+		`^(?:а |и )?где еще(?: идет| показывают| есть)?$`,
+		`^(?:а |и )?(?:в )?других кинотеатрах$`,
+		`^(?:да|давай|ага|расскажи|назови)$`,
+	)
+	return t
+}
 
-	currentTime := time.Now().In(timezone)
+// Later creates a template for requests of showtimes after the spoken ones
+func Later() *Template {
+	t, _ := New(
+		`^(?:а |и |покажи |давай |есть )*(?:позже|попозже|позднее|более поздние|поздние)(?: сеансы| сеансов| есть| можно)?$`,
+		`^(?:а )?(?:есть )?(?:что-нибудь|что нибудь|что-то|что то|сеансы) (?:позже|попозже|позднее)$`,
+	)
+	return t
+}
 
-	session := aliceRequest.Session
+// dateRule resolves a matched date expression relative to the current user time
+type dateRule struct {
+	re      *regexp.Regexp
+	resolve func(match []string, now time.Time) (time.Time, bool)
+}
 
-	location, err := p.storage.Get(userID)
-	if err != nil {
-		log.Printf("[ERROR] Failed to load data from storage: %v", err)
-		return say(session, p.getAnswer("SYSTEM_ERROR"))
-	}
+var weekdays = map[string]time.Weekday{
+	"понедельник": time.Monday,
+	"вторник":     time.Tuesday,
+	"среду":       time.Wednesday,
+	"четверг":     time.Thursday,
+	"пятницу":     time.Friday,
+	"субботу":     time.Saturday,
+	"воскресенье": time.Sunday,
+}
 
-	phrase := aliceRequest.Request.Command
+var months = map[string]int{
+	"января":   1,
+	"февраля":  2,
+	"марта":    3,
+	"апреля":   4,
+	"мая":      5,
+	"июня":     6,
+	"июля":     7,
+	"августа":  8,
+	"сентября": 9,
+	"октября":  10,
+	"ноября":   11,
+	"декабря":  12,
+}
 
-	log.Printf("[INFO] User %s says: %s", userID, phrase)
+// genitive ordinals used for days of month: "двадцатого", "тридцать первого"
+var ordinals = buildOrdinals()
 
-	// if location is unknown, we have to retrieve it from user
-	if !location.Completed && !location.InProgress {
-		location.InProgress = true
-		if err := p.storage.Save(userID, location); err != nil {
-			log.Printf("[ERROR] Failed to save a user progress: %v", err)
-			return say(session, p.getAnswer("SYSTEM_ERROR"))
-		}
-		return say(session, p.getAnswer("ASK_LOCATION"))
+func buildOrdinals() map[string]int {
+	units := []string{"первого", "второго", "третьего", "четвертого", "пятого", "шестого", "седьмого", "восьмого", "девятого"}
+	teens := []string{"десятого", "одиннадцатого", "двенадцатого", "тринадцатого", "четырнадцатого",
+		"пятнадцатого", "шестнадцатого", "семнадцатого", "восемнадцатого", "девятнадцатого"}
 
-	} else if !location.Completed && location.InProgress {
-		// if location retrieval is in progress, we should complete it
-		newLocation, err := GetUserLocation(phrase)
-		if err != nil {
-			if err == UnknownLocationError {
-				return say(session, p.getAnswer("UNKNOWN_LOCATION"))
-			}
-			log.Printf("[ERROR] failed to get info from yandex: %v", err)
-			return say(session, p.getAnswer("SYSTEM_ERROR"))
-		}
+	result := map[string]int{"двадцатого": 20, "тридцатого": 30, "тридцать первого": 31}
+	for i, unit := range units {
+		result[unit] = i + 1
+		result["двадцать "+unit] = 21 + i
+	}
+	for i, teen := range teens {
+		result[teen] = 10 + i
+	}
+	return result
+}
 
-		newLocation.Completed = true
-		newLocation.InProgress = false
-		if err = p.storage.Save(userID, newLocation); err != nil {
-			log.Printf("[ERROR] Failed to save a user location: %v", err)
-			return say(session, p.getAnswer("SYSTEM_ERROR"))
+// alternation builds a regular expression group body from map keys, longest first
+func alternation(words map[string]int) string {
+	keys := make([]string, 0, len(words))
+	for word := range words {
+		keys = append(keys, word)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
 		}
+		return keys[i] < keys[j]
+	})
+	return strings.Join(keys, "|")
+}
 
-		return say(session, p.getAnswer("LOCATION_CONFIRMED"))
-	} else {
-		// buttons actions
-		if phrase == "" {
-			return sayWithButtons(session, p.getAnswer("WELCOME"))
-		} else if phrase == getAddress {
-			log.Printf("[INFO] User %s GET_ADDRESS request", userID)
-			address := "Ваш адрес: город " + location.City
-			if location.Subway != "" {
-				address += ", метро " + location.Subway
+var dateRules = []dateRule{
+	{
+		regexp.MustCompile(`(?:^|\s)(?:на |в )?(сегодня|послезавтра|завтра)(?:\s|$)`),
+		func(match []string, now time.Time) (time.Time, bool) {
+			days := map[string]int{"сегодня": 0, "завтра": 1, "послезавтра": 2}
+			return startOfDay(now).AddDate(0, 0, days[match[1]]), true
+		},
+	},
+	{
+		regexp.MustCompile(`(?:^|\s)(?:в|во|на) (понедельник|вторник|среду|четверг|пятницу|субботу|воскресенье)(?:\s|$)`),
+		func(match []string, now time.Time) (time.Time, bool) {
+			return nextWeekday(now, weekdays[match[1]]), true
+		},
+	},
+	// the schedule is searched for a single day, so the weekend is its first day: Saturday or today on Sunday
+	{
+		regexp.MustCompile(`(?:^|\s)(?:на|в) (?:выходных|выходные)(?:\s|$)`),
+		func(match []string, now time.Time) (time.Time, bool) {
+			if now.Weekday() == time.Sunday {
+				return startOfDay(now), true
 			}
-			return sayWithButtons(session, address)
-		} else if phrase == changeAddress {
-			log.Printf("[INFO] User %s CHANGE_ADDRESS request", userID)
-			location.InProgress = true
-			location.Completed = false
-			if err := p.storage.Save(userID, location); err != nil {
-				log.Printf("[ERROR] Fail to change a user address: %v", err)
-				return say(session, p.getAnswer("SYSTEM_ERROR"))
+			return nextWeekday(now, time.Saturday), true
+		},
+	},
+	{
+		regexp.MustCompile(`(?:^|\s)(?:на )?(\d{1,2}) (` + alternation(months) + `)(?:\s|$)`),
+		func(match []string, now time.Time) (time.Time, bool) {
+			day, _ := strconv.Atoi(match[1])
+			return nearestDate(now, time.Month(months[match[2]]), day)
+		},
+	},
+	{
+		regexp.MustCompile(`(?:^|\s)(?:на )?(` + alternation(ordinals) + `) (` + alternation(months) + `)(?:\s|$)`),
+		func(match []string, now time.Time) (time.Time, bool) {
+			return nearestDate(now, time.Month(months[match[2]]), ordinals[match[1]])
+		},
+	},
+	{
+		// a bare ordinal like "двадцатого" is too ambiguous in the middle of a movie title
+		regexp.MustCompile(`(?:^|\s)(?:на )?(?:(` + alternation(ordinals) + `)(?: числа)?|(\d{1,2})(?:-го| числа))$`),
+		func(match []string, now time.Time) (time.Time, bool) {
+			day, ok := ordinals[match[1]]
+			if !ok {
+				day, _ = strconv.Atoi(match[2])
 			}
+			return nearestMonthDay(now, day)
+		},
+	},
+	{
+		regexp.MustCompile(`(?:^|\s)(?:на )?(\d{1,2})\.(\d{1,2})(?:\s|$)`),
+		func(match []string, now time.Time) (time.Time, bool) {
+			day, _ := strconv.Atoi(match[1])
+			month, _ := strconv.Atoi(match[2])
+			if month < 1 || month > 12 {
+				return time.Time{}, false
+			}
+			return nearestDate(now, time.Month(month), day)
+		},
+	},
+}
 
-			return say(session, p.getAnswer("CHANGE_ADDRESS"))
-		}
-
-		// if location exists, we should process requests as is
-		extracted, ok := p.template.Matches(strings.ToLower(phrase))
-		if !ok {
-			return sayWithButtons(session, p.getAnswer("UNKNOWN_MOVIE"))
-		}
-		movie, ok := extracted["movie"]
+// ExtractDate finds a day of the schedule in a user phrase and returns it
+// with the phrase without the date words. If there is no date, today is returned.
+func ExtractDate(phrase string, now time.Time) (time.Time, string) {
+	for _, rule := range dateRules {
+		groups, rest, ok := matchRule(rule.re, phrase)
 		if !ok {
-			return sayWithButtons(session, p.getAnswer("UNKNOWN_MOVIE"))
-		}
-
-		searchResult, err := GetRamblerShowtimes(movie, location.City, location.Subway, timezone)
-
-		if err != nil {
-			if err == NoSuchMovie {
-				return sayWithButtons(session, p.getAnswer("UNKNOWN_MOVIE"))
-			}
-			log.Printf("[ERROR] failed to load data from rambler: %v", err)
-			return sayTerminal(session, p.getAnswer("SYSTEM_ERROR"))
+			continue
 		}
-		log.Printf("[INFO] User %s found cinemas with movie %s: %d", userID, movie, len(searchResult.Cinemas))
-		if isNoShowtimes(searchResult) {
-			return sayWithButtons(session, p.getAnswer("NO_SHOWTIMES"))
+		if date, ok := rule.resolve(groups, now); ok {
+			return date, rest
 		}
-		return sayWithButtons(session, constructShowtimesPhrase(searchResult, currentTime))
 	}
+	return startOfDay(now), phrase
 }
 
-func constructShowtimesPhrase(searchResult *SearchResult, userTime time.Time) string {
-	showtimes := findNearestShowtimes(searchResult, userTime)
-	var phrase string
-	if len(showtimes) > 3 {
-		// lots of cinemas nearby case
-		phrase = "Я выбрала 3 кинотеатра с ближайшими сеансами. "
+// matchRule finds a regular expression in the phrase and returns its groups
+// and the phrase without the matched part
+func matchRule(re *regexp.Regexp, phrase string) ([]string, string, bool) {
+	match := re.FindStringSubmatchIndex(phrase)
+	if match == nil {
+		return nil, phrase, false
 	}
-	var builder strings.Builder
-
-	for i := 0; i < len(showtimes); i++ {
-		if i > 2 {
-			break
+	groups := make([]string, 0, len(match)/2)
+	for i := 0; i < len(match); i += 2 {
+		if match[i] < 0 {
+			groups = append(groups, "")
+			continue
 		}
+		groups = append(groups, phrase[match[i]:match[i+1]])
+	}
+	rest := phrase[:match[0]] + " " + phrase[match[1]:]
+	return groups, strings.Join(strings.Fields(rest), " "), true
+}
 
-		showtime := showtimes[i]
-		builder.WriteString("В " + showtime.Name + " ")
-		if i == 0 {
-			if len(showtime.Showtimes) == 1 {
-				builder.WriteString("фильм начинается в " + showtime.Showtimes[0].Time.Format("15:04"))
-			} else {
-				builder.WriteString("сеансы начинаются в " + showtime.Showtimes[0].Time.Format("15:04"))
-				builder.WriteString(" и в " + showtime.Showtimes[1].Time.Format("15:04"))
-			}
-			builder.WriteString(". ")
-		} else {
-			if len(showtime.Showtimes) == 1 {
-				builder.WriteString("в " + showtime.Showtimes[0].Time.Format("15:04"))
-			} else {
-				builder.WriteString("в " + showtime.Showtimes[0].Time.Format("15:04"))
-				builder.WriteString(" и в " + showtime.Showtimes[1].Time.Format("15:04"))
-			}
-			builder.WriteString(". ")
+// nextWeekday returns the nearest day with a given weekday, today included
+func nextWeekday(now time.Time, weekday time.Weekday) time.Time {
+	days := (int(weekday) - int(now.Weekday()) + 7) % 7
+	return startOfDay(now).AddDate(0, 0, days)
+}
+
+// nearestMonthDay returns the day of the current month or of the next one if it has already passed
+func nearestMonthDay(now time.Time, day int) (time.Time, bool) {
+	for i := 0; i < 2; i++ {
+		month := now.AddDate(0, i, 1-now.Day())
+		date := time.Date(month.Year(), month.Month(), day, 0, 0, 0, 0, now.Location())
+		if date.Month() == month.Month() && !date.Before(startOfDay(now)) {
+			return date, true
 		}
 	}
+	return time.Time{}, false
+}
 
-	return phrase + builder.String()
+// nearestDate returns the date in the current year or in the next one if it has already passed
+func nearestDate(now time.Time, month time.Month, day int) (time.Time, bool) {
+	date := time.Date(now.Year(), month, day, 0, 0, 0, 0, now.Location())
+	if date.Month() != month {
+		// 31 of february and so on
+		return time.Time{}, false
+	}
+	if date.Before(startOfDay(now)) {
+		date = date.AddDate(1, 0, 0)
+	}
+	return date, true
 }
 
-// returns top 2 nearest showtimes based on current time for each cinema
-func findNearestShowtimes(searchResult *SearchResult, userTime time.Time) []Cinema {
-	showtimes := make([]Cinema, 0)
+// Entities contains date and time constraints found in a user phrase.
+// All times are in the user's timezone, zero From or To means there is no bound.
+type Entities struct {
+	Date time.Time
+	From time.Time
+	To   time.Time
+	// false when the phrase has no date and Date is today
+	HasDate bool
+	Format  FormatTag
+	// the highest price in roubles, zero means any price
+	MaxPrice int
+	// the user asked for the cheapest showtimes
+	Cheapest bool
+}
 
-	for _, cinema := range searchResult.Cinemas {
-		sortedShowtimes := make([]Showtime, 0)
+// IsEmpty checks if nothing was found in the phrase
+func (e Entities) IsEmpty() bool {
+	return !e.HasDate && e.From.IsZero() && e.To.IsZero() && e.Format == 0 && e.MaxPrice == 0 && !e.Cheapest
+}
 
-		for _, showtime := range cinema.Showtimes {
-			if showtime.Time.After(userTime) {
-				continue
-			}
-			sortedShowtimes = append(sortedShowtimes, showtime)
-		}
-		if len(sortedShowtimes) == 0 {
-			continue
-		}
+// Refine applies entities of a follow-up phrase to the previous request:
+// a new date keeps the asked time of day, a new time keeps the asked date
+// and a format or a price is changed only if it was said again
+func (e Entities) Refine(previous Entities) Entities {
+	refined := previous
+	refined.From, refined.To = time.Time{}, time.Time{}
+	if e.Format != 0 {
+		refined.Format = e.Format
+	}
+	if e.MaxPrice != 0 {
+		refined.MaxPrice = e.MaxPrice
+	}
+	refined.Cheapest = refined.Cheapest || e.Cheapest
+	if e.HasDate {
+		refined.Date, refined.HasDate = e.Date, true
+	}
 
-		sort.Slice(sortedShowtimes, func(i, j int) bool { return sortedShowtimes[i].Time.Before(sortedShowtimes[j].Time) })
-		copyCinema := Cinema{
-			Name:      cinema.Name,
-			Address:   cinema.Address,
-			Subway:    cinema.Subway,
-			Showtimes: sortedShowtimes,
-		}
-		showtimes = append(showtimes, copyCinema)
+	from, to, base := e.From, e.To, e.Date
+	if from.IsZero() && to.IsZero() {
+		from, to, base = previous.From, previous.To, previous.Date
+	}
+	if !from.IsZero() {
+		refined.From = refined.Date.Add(from.Sub(base))
 	}
+	if !to.IsZero() {
+		refined.To = refined.Date.Add(to.Sub(base))
+	}
+	return refined
+}
 
-	sort.Slice(showtimes, func(i, j int) bool {
-		return showtimes[i].Showtimes[0].Time.Before(showtimes[j].Showtimes[0].Time)
-	})
-	return showtimes
+// hours in all cases they are used with prepositions: "в семь", "после семи", "к семи"
+var hourWords = map[string]int{
+	"час": 1, "часа": 1, "одного": 1, "одному": 1,
+	"два": 2, "двух": 2, "двум": 2,
+	"три": 3, "трех": 3, "трем": 3,
+	"четыре": 4, "четырех": 4, "четырем": 4,
+	"пять": 5, "пяти": 5,
+	"шесть": 6, "шести": 6,
+	"семь": 7, "семи": 7,
+	"восемь": 8, "восьми": 8,
+	"девять": 9, "девяти": 9,
+	"десять": 10, "десяти": 10,
+	"одиннадцать": 11, "одиннадцати": 11,
+	"двенадцать": 12, "двенадцати": 12,
+	"полночь": 0, "полуночи": 0,
+	"полдень": 12, "полудня": 12,
 }
 
-func isNoShowtimes(search *SearchResult) bool {
-	if search == nil {
-		return true
-	}
-	for _, cinema := range search.Cinemas {
-		if len(cinema.Showtimes) != 0 {
-			return false
-		}
-	}
-	return true
+// hour, optional minutes and optional part of day qualifier, like "восьми вечера" or "19:30"
+var hourPattern = `(\d{1,2}(?::\d{2})?|` + alternation(hourWords) + `)(?: часов| часа| час)?(?: (утра|дня|вечера|ночи))?`
+
+// timeRule resolves a matched time expression to a range of offsets from the start of the schedule day.
+// Zero offset means there is no bound. Part is a qualifier of the part of day said separately, if any.
+type timeRule struct {
+	re      *regexp.Regexp
+	resolve func(match []string, part string) (from, to time.Duration, ok bool)
 }
 
-func sayWithButtons(session Session, phrase string) *AliceResponse {
-	response := say(session, phrase)
-	response.Response.Buttons = []Button{
-		Button{
-			Title: "Мой адрес",
-			Hide:  true,
+var timeRules = []timeRule{
+	{
+		regexp.MustCompile(`(?:^|\s)с ` + hourPattern + ` до ` + hourPattern + `(?:\s|$)`),
+		func(match []string, part string) (time.Duration, time.Duration, bool) {
+			from, ok := hourOffset(match[1], qualifierOr(match[2], part))
+			if !ok {
+				return 0, 0, false
+			}
+			to, ok := hourOffset(match[3], qualifierOr(match[4], part))
+			return from, to, ok && to > from
 		},
-		Button{
-			Title: "Сменить адрес",
-			Hide:  true,
+	},
+	{
+		regexp.MustCompile(`(?:^|\s)(?:после|позже|не раньше|начиная с) ` + hourPattern + `(?:\s|$)`),
+		func(match []string, part string) (time.Duration, time.Duration, bool) {
+			from, ok := hourOffset(match[1], qualifierOr(match[2], part))
+			return from, 0, ok
 		},
-	}
-	return response
+	},
+	{
+		regexp.MustCompile(`(?:^|\s)(?:до|раньше|не позже|не позднее) ` + hourPattern + `(?:\s|$)`),
+		func(match []string, part string) (time.Duration, time.Duration, bool) {
+			to, ok := hourOffset(match[1], qualifierOr(match[2], part))
+			return 0, to, ok
+		},
+	},
+	{
+		regexp.MustCompile(`(?:^|\s)(?:в|к|около|примерно в) ` + hourPattern + `(\s|$)`),
+		func(match []string, part string) (time.Duration, time.Duration, bool) {
+			hour, qualifier, tail := match[1], match[2], match[3]
+			// "в 9 роту" or "в два ствола" are parts of titles, so bare hours are times only at the end of the phrase
+			if qualifier == "" && tail != "" && !strings.Contains(hour, ":") {
+				return 0, 0, false
+			}
+			at, ok := hourOffset(hour, qualifierOr(qualifier, part))
+			return at - 30*time.Minute, at + time.Hour, ok
+		},
+	},
+}
 
+// partOfDay is a range of hours, "to" may be greater than 24 for night ranges.
+// Qualifier resolves bare hours said with the part of day: "утром после девяти".
+type partOfDay struct {
+	from, to  int
+	qualifier string
 }
 
-func say(session Session, phrase string) *AliceResponse {
-	response := getResponseStub(session)
-	response.Response.Tts = phrase
-	response.Response.Text = phrase
-	return response
+var partsOfDay = map[string]partOfDay{
+	"утром":    {6, 12, "утра"},
+	"с утра":   {6, 12, "утра"},
+	"днем":     {12, 17, "дня"},
+	"вечером":  {17, 24, "вечера"},
+	"вечерком": {17, 24, "вечера"},
+	"ночью":    {22, 24 + nightShowtimesHour, "ночи"},
 }
 
-func sayTerminal(session Session, phrase string) *AliceResponse {
-	response := say(session, phrase)
-	response.Response.EndSession = true
-	return response
+var partOfDayRe = regexp.MustCompile(`(?:^|\s)(с утра|утром|днем|вечером|вечерком|ночью)(?:\s|$)`)
+
+// formatRules are phrases of showtime formats: "в аймаксе", "в 3д", "с субтитрами"
+var formatRules = []struct {
+	re  *regexp.Regexp
+	tag FormatTag
+}{
+	{regexp.MustCompile(`(?:^|\s)(?:в |на )?(?:аймаксе|аймакс|имаксе|имакс|imax)(?:\s|$)`), FormatIMAX},
+	{regexp.MustCompile(`(?:^|\s)(?:в |на )?(?:3д|3d|3-д|3-d|три д|три дэ|тридэ)(?:\s|$)`), Format3D},
+	{regexp.MustCompile(`(?:^|\s)(?:в |на )?(?:4дх|4dx|четыре дэ икс)(?:\s|$)`), Format4DX},
+	{regexp.MustCompile(`(?:^|\s)(?:в |с )?(?:долби атмос|dolby atmos|атмосе|атмос)(?:\s|$)`), FormatAtmos},
+	{regexp.MustCompile(`(?:^|\s)(?:на языке оригинала|на оригинальном языке|в оригинале|на английском|без перевода|без дубляжа)(?:\s|$)`), FormatOriginal},
+	{regexp.MustCompile(`(?:^|\s)(?:с субтитрами|с сабами)(?:\s|$)`), FormatSubtitles},
 }
 
-func getResponseStub(session Session) *AliceResponse {
-	return &AliceResponse{
-		Version: "1.0",
-		Session: session,
+// ExtractFormat finds showtime formats in a user phrase and returns them with the phrase without format words
+func ExtractFormat(phrase string) (FormatTag, string) {
+	var tags FormatTag
+	for _, rule := range formatRules {
+		if _, rest, ok := matchRule(rule.re, phrase); ok {
+			tags |= rule.tag
+			phrase = rest
+		}
 	}
+	return tags, phrase
 }
 
-func (p *MessageProcessor) getAnswer(tag string) string {
-	answers := p.answers[tag]
-	return answers[rand.Intn(len(answers))]
+// price limits: "до 300 рублей", "не дороже 500"
+var maxPriceRes = []*regexp.Regexp{
+	regexp.MustCompile(`(?:^|\s)(?:до|за|не больше|меньше|в пределах) (\d+) (?:рублей|рубля|рубль|руб|р)(?:\s|$)`),
+	regexp.MustCompile(`(?:^|\s)(?:не дороже|дешевле) (\d+)(?: рублей| рубля| рубль| руб| р)?(?:\s|$)`),
 }
 
-func availableAnswers() map[string][]string {
-	answers := make(map[string][]string)
+var cheapestRe = regexp.MustCompile(`(?:^|\s)(?:где |какие |что )?(?:подешевле|дешевле|самые дешевые|самый дешевый|дешевые|недорогие|недорого)(?: билеты| сеансы)?(?:\s|$)`)
+
+// ExtractPrice finds a price limit and a request for cheap showtimes in a user phrase
+// and returns them with the phrase without price words
+func ExtractPrice(phrase string) (int, bool, string) {
+	var maxPrice int
+	for _, re := range maxPriceRes {
+		if groups, rest, ok := matchRule(re, phrase); ok {
+			maxPrice, _ = strconv.Atoi(groups[1])
+			phrase = rest
+			break
+		}
+	}
+	_, rest, cheapest := matchRule(cheapestRe, phrase)
+	return maxPrice, cheapest, rest
+}
+
+// ExtractEntities finds a date, a time range, a part of day, a format and a price in a user phrase
+// and returns them with the phrase without the found words
+func ExtractEntities(phrase string, now time.Time) (Entities, string) {
+	phrase = strings.Replace(phrase, "ё", "е", -1)
+	format, phrase := ExtractFormat(phrase)
+	maxPrice, cheapest, phrase := ExtractPrice(phrase)
+	date, rest := ExtractDate(phrase, now)
+	hasDate := rest != phrase
+
+	// the part of day is found first, it tells bare hours of the morning from evening ones
+	partGroups, withoutPart, hasPart := matchRule(partOfDayRe, rest)
+	var part partOfDay
+	if hasPart {
+		part = partsOfDay[partGroups[1]]
+		rest = withoutPart
+	}
+
+	var from, to time.Duration
+	for _, rule := range timeRules {
+		groups, withoutTime, ok := matchRule(rule.re, rest)
+		if !ok {
+			continue
+		}
+		if ruleFrom, ruleTo, ok := rule.resolve(groups, part.qualifier); ok {
+			from, to = ruleFrom, ruleTo
+			rest = withoutTime
+			break
+		}
+	}
 
-	answers["ASK_LOCATION"] = []string{
-		"Привет! А в каком городе и на какой станции метро, если оно есть, вы живете?",
+	if hasPart {
+		partFrom, partTo := time.Duration(part.from)*time.Hour, time.Duration(part.to)*time.Hour
+		// explicit time is more precise than a part of day
+		if from < partFrom {
+			from = partFrom
+		}
+		if to == 0 || to > partTo {
+			to = partTo
+		}
 	}
-	answers["UNKNOWN_LOCATION"] = []string{
-		"Что-то я не знаю такого адреса. А повторите пожалуйста в таком виде: \"Москва, метро Октябрьская\" или просто скажите название города, если метро нет, например \"Абакан\"",
-		"Этот адрес мне неизвестен, повторите ещё",
-		"Такого адреса я не знаю, повторите в виде \"Москва, метро Дмитровская\" или скажите просто название города, если у вас нет метро\"",
-		"Не могу найти такой адрес, попробуйте ещё",
+
+	// a bare ordinal date becomes the last word only after the time is removed: "двадцатого после семи"
+	if !hasDate && rest != phrase {
+		withoutTime := rest
+		date, rest = ExtractDate(withoutTime, now)
+		hasDate = rest != withoutTime
 	}
-	answers["LOCATION_CONFIRMED"] = []string{
-		"Отлично! А теперь скажите название фильма, который вы хотите найти",
-		"Хорошо, я запомнила. Скажите название фильма, который вы хотите найти",
+
+	entities := Entities{Date: date, HasDate: hasDate, Format: format, MaxPrice: maxPrice, Cheapest: cheapest}
+	if from > 0 {
+		entities.From = date.Add(from)
 	}
-	answers["UNKNOWN_MOVIE"] = []string{
-		"Я вас почему то не понимаю, скажите название фильма, например: \"Звездные Войны\"",
-		"Почему-то не могу найти такой фильм, попробуйте сказать название фильма: \"Интерстеллар\"",
-		"Что-то я не знаю такого фильма, скажите, пожалуйста, название фильма, например: \"Тор\"",
+	if to > 0 {
+		entities.To = date.Add(to)
 	}
-	answers["NO_SHOWTIMES"] = []string{
-		"Похоже на то, что в вашем регионе сейчас нет сеансов этого фильма",
-		"Не могу найти сеансов. Похоже, что в вашем регионе сейчас этот фильм не идет",
-		"По вашему адресу сейчас нет сеансов. Увы. Но вы всегда можете пойти на пробежку, спорт это очень полезно!",
-		"Сеансов на сегодня я не вижу. Придется заняться чем-то ещё",
+	return entities, rest
+}
+
+// words around entities in short follow-up questions: "а на завтра?", "а что есть вечером?"
+var followUpWords = map[string]bool{
+	"а":      true,
+	"и":      true,
+	"ну":     true,
+	"на":     true,
+	"тогда":  true,
+	"если":   true,
+	"как":    true,
+	"насчет": true,
+	"что":    true,
+	"есть":   true,
+	"можно":  true,
+	"там":    true,
+	"сеансы": true,
+	"сеанс":  true,
+	"будут":  true,
+	"будет":  true,
+}
+
+// IsFollowUp checks if a phrase without entities contains no new request, only words of a follow-up question
+func IsFollowUp(rest string) bool {
+	for _, word := range splitWords(rest) {
+		if !followUpWords[word] {
+			return false
+		}
 	}
-	answers["CHANGE_ADDRESS"] = []string{
-		"Хорошо, давайте поменяем адрес. Скажите в каком городе и на какой станции метро, если оно есть, вы живете",
+	return true
+}
+
+// qualifierOr returns the qualifier said with the hour or the one of the part of day
+func qualifierOr(qualifier, part string) string {
+	if qualifier == "" {
+		return part
+	}
+	return qualifier
+}
+
+// hourOffset converts an hour said by the user to the offset from the start of the schedule day.
+// Without a qualifier small hours are treated as evening ones, nobody goes to the cinema at 7 am.
+func hourOffset(rawHour, qualifier string) (time.Duration, bool) {
+	var hour, minute int
+	if h, ok := hourWords[rawHour]; ok {
+		hour = h
+	} else {
+		parts := strings.SplitN(rawHour, ":", 2)
+		var err error
+		if hour, err = strconv.Atoi(parts[0]); err != nil {
+			return 0, false
+		}
+		if len(parts) == 2 {
+			if minute, err = strconv.Atoi(parts[1]); err != nil || minute > 59 {
+				return 0, false
+			}
+		}
+	}
+	if hour > 24 {
+		return 0, false
 	}
-	answers["SYSTEM_ERROR"] = []string{
-		"Что-то мне стало нехорошо, попробуйте позже, пожалуйста",
-		"Что-то мне сегодня не очень, попробуйте через некоторое время",
+
+	switch qualifier {
+	case "утра":
+		if hour == 12 {
+			hour = 0
+		}
+	case "ночи":
+		// "в 11 ночи" is late evening, "в 2 ночи" is after midnight
+		if hour == 12 {
+			hour = 0
+		} else if hour >= 6 && hour < 12 {
+			hour += 12
+		}
+	case "дня", "вечера":
+		if hour < 12 {
+			hour += 12
+		}
+	default:
+		if hour > 0 && hour < 10 && !strings.Contains(rawHour, ":") {
+			hour += 12
+		}
 	}
-	answers["WELCOME"] = []string{
-		"Привет, какой фильм вы хотите посмотреть?",
-		"Привет, на какой фильм мне найти сеансы?",
+
+	if hour < nightShowtimesHour {
+		// night showtimes belong to the previous schedule day
+		hour += 24
 	}
-	return answers
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, true
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"regexp"
 	"testing"
+	"time"
 )
 
 func TestTemplate(t *testing.T) {
@@ -61,3 +62,159 @@ func TestTemplates(t *testing.T) {
 		}
 	}
 }
+
+func TestExtractDate(t *testing.T) {
+	// thursday
+	now := time.Date(2018, 3, 15, 18, 30, 0, 0, time.UTC)
+	var td = []struct {
+		Phrase string
+		Date   time.Time
+		Rest   string
+	}{
+		{"расписание пассажира", time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC), "расписание пассажира"},
+		{"расписание пассажира на завтра", time.Date(2018, 3, 16, 0, 0, 0, 0, time.UTC), "расписание пассажира"},
+		{"послезавтра черная пантера", time.Date(2018, 3, 17, 0, 0, 0, 0, time.UTC), "черная пантера"},
+		{"хочу в субботу на излом времени", time.Date(2018, 3, 17, 0, 0, 0, 0, time.UTC), "хочу на излом времени"},
+		{"когда идет пассажир в четверг", time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC), "когда идет пассажир"},
+		{"сеансы тора во вторник", time.Date(2018, 3, 20, 0, 0, 0, 0, time.UTC), "сеансы тора"},
+		{"пассажир на выходных", time.Date(2018, 3, 17, 0, 0, 0, 0, time.UTC), "пассажир"},
+		{"пассажир 20 марта", time.Date(2018, 3, 20, 0, 0, 0, 0, time.UTC), "пассажир"},
+		{"пассажир на 2 января", time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC), "пассажир"},
+		{"пассажир 31 февраля", time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC), "пассажир 31 февраля"},
+		{"9 рота", time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC), "9 рота"},
+	}
+
+	for _, tr := range td {
+		date, rest := ExtractDate(tr.Phrase, now)
+		if !date.Equal(tr.Date) {
+			t.Errorf("wrong date for %s: %v", tr.Phrase, date)
+		}
+		if rest != tr.Rest {
+			t.Errorf("wrong rest for %s: %s", tr.Phrase, rest)
+		}
+	}
+}
+
+func TestShowtimeAt(t *testing.T) {
+	day := time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC)
+	showtime, err := showtimeAt(day, " 19:30 ")
+	if err != nil || !showtime.Equal(time.Date(2018, 3, 15, 19, 30, 0, 0, time.UTC)) {
+		t.Errorf("wrong showtime: %v %v", showtime, err)
+	}
+	showtime, err = showtimeAt(day, "01:10")
+	if err != nil || !showtime.Equal(time.Date(2018, 3, 16, 1, 10, 0, 0, time.UTC)) {
+		t.Errorf("night showtime should be on the next day: %v %v", showtime, err)
+	}
+}
+
+func TestExtractEntities(t *testing.T) {
+	// thursday
+	now := time.Date(2018, 3, 15, 12, 0, 0, 0, time.UTC)
+	at := func(day, hour, minute int) time.Time { return time.Date(2018, 3, day, hour, minute, 0, 0, time.UTC) }
+	var td = []struct {
+		Phrase string
+		Date   time.Time
+		From   time.Time
+		To     time.Time
+		Rest   string
+	}{
+		{"пассажир", at(15, 0, 0), time.Time{}, time.Time{}, "пассажир"},
+		{"пассажир после семи", at(15, 0, 0), at(15, 19, 0), time.Time{}, "пассажир"},
+		{"пассажир в 19:30", at(15, 0, 0), at(15, 19, 0), at(15, 20, 30), "пассажир"},
+		{"хочу на пассажира к восьми вечера", at(15, 0, 0), at(15, 19, 30), at(15, 21, 0), "хочу на пассажира"},
+		{"завтра вечером черная пантера", at(16, 0, 0), at(16, 17, 0), at(17, 0, 0), "черная пантера"},
+		{"пассажир в субботу утром", at(17, 0, 0), at(17, 6, 0), at(17, 12, 0), "пассажир"},
+		{"пассажир ночью", at(15, 0, 0), at(15, 22, 0), at(16, 5, 0), "пассажир"},
+		{"пассажир с 18 до 21", at(15, 0, 0), at(15, 18, 0), at(15, 21, 0), "пассажир"},
+		{"пассажир до 22:00 послезавтра", at(17, 0, 0), time.Time{}, at(17, 22, 0), "пассажир"},
+		{"пассажир вечером после девяти", at(15, 0, 0), at(15, 21, 0), at(16, 0, 0), "пассажир"},
+		// the part of day resolves bare hours
+		{"пассажир утром после девяти", at(15, 0, 0), at(15, 9, 0), at(15, 12, 0), "пассажир"},
+		{"пассажир днем в три", at(15, 0, 0), at(15, 14, 30), at(15, 16, 0), "пассажир"},
+		{"пассажир после часа ночи", at(15, 0, 0), at(16, 1, 0), time.Time{}, "пассажир"},
+		{"пассажир в 11 ночи", at(15, 0, 0), at(15, 22, 30), at(16, 0, 0), "пассажир"},
+		{"пассажир в одиннадцать ночи", at(15, 0, 0), at(15, 22, 30), at(16, 0, 0), "пассажир"},
+		{"пассажир в 10 ночи", at(15, 0, 0), at(15, 21, 30), at(15, 23, 0), "пассажир"},
+		{"пассажир в 2 ночи", at(15, 0, 0), at(16, 1, 30), at(16, 3, 0), "пассажир"},
+		{"пассажир двадцатого после семи", at(20, 0, 0), at(20, 19, 0), time.Time{}, "пассажир"},
+		{"пассажир двадцать первого марта", at(21, 0, 0), time.Time{}, time.Time{}, "пассажир"},
+		{"пассажир на первого", time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC), time.Time{}, time.Time{}, "пассажир"},
+		{"первый мститель", at(15, 0, 0), time.Time{}, time.Time{}, "первый мститель"},
+		{"хочу в 9 роту", at(15, 0, 0), time.Time{}, time.Time{}, "хочу в 9 роту"},
+		{"хочу в два ствола", at(15, 0, 0), time.Time{}, time.Time{}, "хочу в два ствола"},
+	}
+
+	for _, tr := range td {
+		entities, rest := ExtractEntities(tr.Phrase, now)
+		if !entities.Date.Equal(tr.Date) || !entities.From.Equal(tr.From) || !entities.To.Equal(tr.To) {
+			t.Errorf("wrong entities for %s: %+v", tr.Phrase, entities)
+		}
+		if rest != tr.Rest {
+			t.Errorf("wrong rest for %s: %s", tr.Phrase, rest)
+		}
+	}
+}
+
+func TestRefineEntities(t *testing.T) {
+	now := time.Date(2018, 3, 15, 12, 0, 0, 0, time.UTC)
+	at := func(day, hour, minute int) time.Time { return time.Date(2018, 3, day, hour, minute, 0, 0, time.UTC) }
+	var td = []struct {
+		Previous string
+		Phrase   string
+		Date     time.Time
+		From     time.Time
+		To       time.Time
+	}{
+		{"пассажир вечером", "а завтра", at(16, 0, 0), at(16, 17, 0), at(17, 0, 0)},
+		{"пассажир завтра", "а после восьми", at(16, 0, 0), at(16, 20, 0), time.Time{}},
+		{"пассажир завтра после восьми", "а в субботу утром", at(17, 0, 0), at(17, 6, 0), at(17, 12, 0)},
+		{"пассажир завтра после восьми в 3д", "а где подешевле", at(16, 0, 0), at(16, 20, 0), time.Time{}},
+	}
+
+	for _, tr := range td {
+		previous, _ := ExtractEntities(tr.Previous, now)
+		entities, rest := ExtractEntities(tr.Phrase, now)
+		if entities.IsEmpty() || !IsFollowUp(rest) {
+			t.Errorf("%s is not a follow-up: %s", tr.Phrase, rest)
+		}
+		refined := entities.Refine(previous)
+		if refined.Format != previous.Format || refined.Cheapest != (previous.Cheapest || entities.Cheapest) {
+			t.Errorf("format and price should be kept for %s after %s: %+v", tr.Phrase, tr.Previous, refined)
+		}
+		if !refined.Date.Equal(tr.Date) || !refined.From.Equal(tr.From) || !refined.To.Equal(tr.To) {
+			t.Errorf("wrong entities for %s after %s: %+v", tr.Phrase, tr.Previous, refined)
+		}
+	}
+
+	if _, rest := ExtractEntities("а пассажир завтра", now); IsFollowUp(rest) {
+		t.Errorf("a new movie is not a follow-up")
+	}
+}
+
+func TestRepertoireAndCinemaTemplates(t *testing.T) {
+	repertoire, cinema := RepertoireTemplate(), CinemaTemplate()
+	for _, phrase := range []string{"что сейчас идет в кино", "а что идет", "какие фильмы в прокате", "что посмотреть", "афиша"} {
+		if _, ok := repertoire.Matches(phrase); !ok {
+			t.Errorf("%q is not a repertoire request", phrase)
+		}
+	}
+	for _, phrase := range []string{"а что есть", "что идет в октябре", "когда идет пассажир"} {
+		if _, ok := repertoire.Matches(phrase); ok {
+			t.Errorf("%q is matched as a repertoire request", phrase)
+		}
+	}
+
+	var td = []struct {
+		Phrase string
+		Cinema string
+	}{
+		{"что идет в октябре", "октябре"},
+		{"какие фильмы показывают в кинотеатре пионер", "пионер"},
+		{"расписание кинотеатра формула кино", "формула кино"},
+	}
+	for _, tr := range td {
+		if extracted, ok := cinema.Matches(tr.Phrase); !ok || extracted["cinema"] != tr.Cinema {
+			t.Errorf("wrong cinema for %q: %v", tr.Phrase, extracted)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// words that users add around a movie title
+var fillerWords = map[string]bool{
+	"фильм":       true,
+	"фильма":      true,
+	"фильмы":      true,
+	"кино":        true,
+	"кинофильм":   true,
+	"кинофильма":  true,
+	"мультфильм":  true,
+	"мультфильма": true,
+	"мультик":     true,
+	"мультика":    true,
+	"пожалуйста":  true,
+	"сеансы":      true,
+	"сеанс":       true,
+	"этот":        true,
+	"эту":         true,
+	"этого":       true,
+	"ну":          true,
+	"вот":         true,
+}
+
+// prepositions left before a title by templates: "на пассажира", "про тора"
+var leadingPrepositions = map[string]bool{
+	"на":  true,
+	"в":   true,
+	"во":  true,
+	"у":   true,
+	"для": true,
+	"про": true,
+}
+
+// ending is a case ending with possible nominative endings ordered by probability
+type ending struct {
+	suffix      string
+	nominatives []string
+}
+
+// endings are checked in order, so longer ones go first
+var endings = []ending{
+	{"ого", []string{"ый", "ий", "ой"}},
+	{"его", []string{"ий"}},
+	{"ому", []string{"ый", "ой"}},
+	{"ему", []string{"ий"}},
+	{"ыми", []string{"ые"}},
+	{"ими", []string{"ие"}},
+	{"ую", []string{"ая"}},
+	{"юю", []string{"яя"}},
+	{"ой", []string{"ая", "а"}},
+	{"ей", []string{"яя", "ь"}},
+	{"ым", []string{"ый"}},
+	{"им", []string{"ий"}},
+	{"ых", []string{"ые"}},
+	{"их", []string{"ие"}},
+	{"ом", []string{""}},
+	{"ем", []string{"ь", "й"}},
+	{"ы", []string{"а", ""}},
+	{"у", []string{"а", ""}},
+	{"ю", []string{"я", "ь"}},
+	{"е", []string{"а", ""}},
+	{"а", []string{""}},
+	{"я", []string{"ь", "й"}},
+}
+
+// endings of adjectives that are already in the nominative case
+var nominativeEndings = []string{"ая", "яя", "ый", "ий", "ое", "ее", "ые", "ие"}
+
+// minimal length of a word stem, short words are usually prepositions or already in nominative
+const minStemLength = 3
+
+// maxCandidates limits the amount of provider requests for one phrase
+const maxCandidates = 4
+
+// QueryCandidates generates normalized variants of a movie title extracted from a user phrase.
+// The first candidate is the cleaned title itself, next ones have words converted to the nominative case.
+func QueryCandidates(title string) []string {
+	words := cleanTitle(title)
+	if len(words) == 0 {
+		return []string{}
+	}
+
+	candidates := make([]string, 0, maxCandidates)
+	add := func(words []string) {
+		candidate := strings.Join(words, " ")
+		for _, existing := range candidates {
+			if existing == candidate {
+				return
+			}
+		}
+		if len(candidates) < maxCandidates {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	add(words)
+
+	// all words in the nominative case: "черной пантеры" -> "черная пантера"
+	add(nominativeWords(words, len(words), 0))
+	// only the first word, the rest is usually a genitive attribute: "излома времени" -> "излом времени"
+	add(nominativeWords(words, 1, 0))
+	// less probable endings: "тору" -> "тор"
+	add(nominativeWords(words, len(words), 1))
+
+	return candidates
+}
+
+// cleanTitle lowercases a title, strips quotes, punctuation, filler words and leading prepositions
+func cleanTitle(title string) []string {
+	title = strings.Replace(strings.ToLower(title), "ё", "е", -1)
+	fields := strings.FieldsFunc(title, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+
+	words := make([]string, 0, len(fields))
+	for _, word := range fields {
+		word = strings.Trim(word, "-")
+		if word == "" || fillerWords[word] {
+			continue
+		}
+		if len(words) == 0 && leadingPrepositions[word] {
+			continue
+		}
+		words = append(words, word)
+	}
+	return words
+}
+
+// nominativeWords converts the first count words to the nominative case using the variant of endings
+func nominativeWords(words []string, count, variant int) []string {
+	result := make([]string, len(words))
+	copy(result, words)
+	for i := 0; i < count && i < len(words); i++ {
+		result[i] = nominative(words[i], variant)
+	}
+	return result
+}
+
+// nominative guesses the nominative form of a word by its ending
+func nominative(word string, variant int) string {
+	runes := []rune(word)
+	for _, suffix := range nominativeEndings {
+		if strings.HasSuffix(word, suffix) {
+			return word
+		}
+	}
+	for _, e := range endings {
+		suffix := []rune(e.suffix)
+		if len(runes)-len(suffix) < minStemLength || !strings.HasSuffix(word, e.suffix) {
+			continue
+		}
+		if !isCyrillic(runes) {
+			return word
+		}
+		i := variant
+		if i >= len(e.nominatives) {
+			i = len(e.nominatives) - 1
+		}
+		return string(runes[:len(runes)-len(suffix)]) + e.nominatives[i]
+	}
+	return word
+}
+
+func isCyrillic(runes []rune) bool {
+	for _, r := range runes {
+		if unicode.IsLetter(r) && !unicode.Is(unicode.Cyrillic, r) {
+			return false
+		}
+	}
+	return true
+}
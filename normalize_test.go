@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestQueryCandidates(t *testing.T) {
+	var td = []struct {
+		Title    string
+		Expected string
+	}{
+		{"черной пантеры", "черная пантера"},
+		{"черную пантеру", "черная пантера"},
+		{"черная пантера", "черная пантера"},
+		{"пассажира", "пассажир"},
+		{"излома времени", "излом времени"},
+		{"лару крофт", "лара крофт"},
+		{"тору", "тор"},
+		{"«Тёмную башню»", "темная башня"},
+		{"на фильм пассажир пожалуйста", "пассажир"},
+		{"дэдпула 2", "дэдпул 2"},
+		{"deadpool", "deadpool"},
+	}
+
+	for _, tr := range td {
+		candidates := QueryCandidates(tr.Title)
+		found := false
+		for _, candidate := range candidates {
+			if candidate == tr.Expected {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: %s not found in %v", tr.Title, tr.Expected, candidates)
+		}
+		if len(candidates) > maxCandidates {
+			t.Errorf("%s: too many candidates %v", tr.Title, candidates)
+		}
+	}
+
+	if candidates := QueryCandidates("фильм пожалуйста"); len(candidates) != 0 {
+		t.Errorf("filler words only should produce no candidates: %v", candidates)
+	}
+}
@@ -1,36 +1,163 @@
 package main
 
-import "time"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
 
-// Showtime containes info about movie seance
+// every request to a provider is limited, so providers don't hang after the registry deadline
+const providerTimeout = 10 * time.Second
+
+var httpClient = &http.Client{Timeout: providerTimeout}
+
+// Showtime containes info about movie seance.
+// Price is a string of the provider, MinPrice and MaxPrice are parsed from it in roubles.
+// TicketURL is a page to buy tickets for this showtime, if the provider sells them.
 type Showtime struct {
-	Time   time.Time
-	Price  string
-	Format string
+	Time      time.Time
+	Price     string
+	MinPrice  int
+	MaxPrice  int
+	Format    string
+	TicketURL string
 }
 
-// Cinema contains info about cinema and a slice of showtimes
+// Cinema contains info about cinema and a slice of showtimes.
+// Link and Provider are set when the schedule of the cinema can be loaded.
 type Cinema struct {
 	Name      string
 	Address   string
 	Subway    string
 	Showtimes []Showtime
+	Link      string
+	Provider  string
+	// nil until the address is geocoded
+	Position *Point
 }
 
-// SearchResult contains info about movie seances
+// SearchResult contains info about movie seances, Poster is an image URL if the provider knows it
 type SearchResult struct {
 	Movie   string
 	Cinemas []Cinema
+	Poster  string
+	// zero if the provider doesn't know the year of the movie
+	Year int
 }
 
+// ShowtimeParser is implemented by every source of showtimes (rambler, kinopoisk, etc.).
+// Date is a day of the schedule in the user's timezone.
 type ShowtimeParser interface {
-	GetShowtimes(movieName, city, region string) (*SearchResult, error)
+	GetShowtimes(movieName, city, region string, date time.Time) (*SearchResult, error)
+}
+
+// ContextShowtimeParser is implemented by parsers that stop waiting for providers when the context is done
+type ContextShowtimeParser interface {
+	GetShowtimesContext(ctx context.Context, movieName, city, region string, date time.Time) (*SearchResult, error)
+}
+
+// Movie is a movie found by a provider. Link and Provider are set
+// when the movie can be loaded again without a search by title.
+type Movie struct {
+	Title    string `json:"title"`
+	Year     int    `json:"year,omitempty"`
+	Link     string `json:"link,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	// number of showtimes in the city, zero if the provider doesn't know it
+	Showtimes int `json:"showtimes,omitempty"`
+}
+
+// MovieLister is implemented by providers that know which movies are showing in a city
+type MovieLister interface {
+	GetMovies(city string) ([]Movie, error)
+}
+
+// ExactShowtimeParser is implemented by providers that can load showtimes of a movie found before
+type ExactShowtimeParser interface {
+	GetMovieShowtimes(movie Movie, city, region string, date time.Time) (*SearchResult, error)
+}
+
+// MovieShowtimes are showtimes of a single movie in a cinema
+type MovieShowtimes struct {
+	Movie     string
+	Showtimes []Showtime
+}
+
+// CinemaSchedule contains movies showing in a cinema
+type CinemaSchedule struct {
+	Cinema Cinema
+	Movies []MovieShowtimes
+}
+
+// CinemaParser is implemented by providers that know cinemas of a city and their schedules
+type CinemaParser interface {
+	GetCinemas(city string) ([]Cinema, error)
+	GetCinemaShowtimes(cinema Cinema, city string, date time.Time) (*CinemaSchedule, error)
+}
+
+// NoSuchCinema fires when a cinema can't be loaded by any provider
+var NoSuchCinema = errors.New("no such cinema found")
+
+// AmbiguousMovieError is returned when several movies match the title equally well
+type AmbiguousMovieError struct {
+	Movies []Movie
+}
+
+func (err AmbiguousMovieError) Error() string {
+	return fmt.Sprintf("%d movies match the title", len(err.Movies))
 }
 
 type NoSuchMovieError struct {
 	msg string
 }
 
+// NoSuchMovie fired when movie with given name is not found
+var NoSuchMovie = NoSuchMovieError{}
+
 func (err NoSuchMovieError) Error() string {
 	return "no such movie found: " + err.msg
 }
+
+// cinemas put night showtimes after midnight on the schedule of the previous day
+const nightShowtimesHour = 5
+
+// showtimeAt combines a schedule day with a "15:04" time of the showtime.
+// Night showtimes are moved to the next calendar day.
+func showtimeAt(day time.Time, clock string) (time.Time, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return time.Time{}, err
+	}
+	showtime := time.Date(day.Year(), day.Month(), day.Day(), parsed.Hour(), parsed.Minute(), 0, 0, day.Location())
+	if parsed.Hour() < nightShowtimesHour {
+		showtime = showtime.AddDate(0, 0, 1)
+	}
+	return showtime, nil
+}
+
+// startOfDay truncates time to the midnight in its own timezone
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// fetchPage loads a page of a provider with the limited client
+func fetchPage(link string) (string, error) {
+	resp, err := httpClient.Get(link)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	// an error page has no movies, but it doesn't mean there are none
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to load %s: %s", link, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// thousands separated by a space: "1 200 ₽"
+var thousandsRe = regexp.MustCompile(`(\d)[\s\x{00a0}\x{2009}](\d{3})`)
+
+var numberRe = regexp.MustCompile(`\d+`)
+
+// ParsePrice finds the lowest and the highest price in roubles in a price string of a provider,
+// like "от 250 ₽" or "350–1 200 руб.". Zero means the price is unknown.
+func ParsePrice(raw string) (int, int) {
+	var min, max int
+	for _, number := range numberRe.FindAllString(thousandsRe.ReplaceAllString(raw, "$1$2"), -1) {
+		price, err := strconv.Atoi(number)
+		if err != nil || price == 0 {
+			continue
+		}
+		if min == 0 || price < min {
+			min = price
+		}
+		if price > max {
+			max = price
+		}
+	}
+	return min, max
+}
+
+// SetPrice keeps the price string of a provider and its parsed range
+func (s *Showtime) SetPrice(raw string) {
+	s.Price = strings.TrimSpace(raw)
+	s.MinPrice, s.MaxPrice = ParsePrice(raw)
+}
+
+// spokenPrice describes the price for the user: "за 250 рублей" or "от 250 рублей"
+func spokenPrice(showtime Showtime, phrases Phrases) string {
+	if showtime.MinPrice == 0 {
+		return ""
+	}
+	if showtime.MaxPrice > showtime.MinPrice {
+		return phrases("PRICE_FROM", AnswerData{Count: showtime.MinPrice})
+	}
+	return phrases("PRICE", AnswerData{Count: showtime.MinPrice})
+}
+
+// sortByPrice puts the cheapest showtimes of every cinema first and orders cinemas by their cheapest showtime.
+// The spoken cheapest showtimes and the rest are each kept in time order. Showtimes with unknown prices go last.
+func sortByPrice(cinemas []Cinema) {
+	cheaper := func(a, b Showtime) bool {
+		if a.MinPrice == 0 || b.MinPrice == 0 {
+			return a.MinPrice != 0
+		}
+		return a.MinPrice < b.MinPrice
+	}
+	earlier := func(showtimes []Showtime) {
+		sort.SliceStable(showtimes, func(i, j int) bool { return showtimes[i].Time.Before(showtimes[j].Time) })
+	}
+	cheapest := func(cinema Cinema) Showtime {
+		best := cinema.Showtimes[0]
+		for _, showtime := range cinema.Showtimes {
+			if cheaper(showtime, best) {
+				best = showtime
+			}
+		}
+		return best
+	}
+	sort.SliceStable(cinemas, func(i, j int) bool {
+		return cheaper(cheapest(cinemas[i]), cheapest(cinemas[j]))
+	})
+	for _, cinema := range cinemas {
+		showtimes := cinema.Showtimes
+		sort.SliceStable(showtimes, func(i, j int) bool { return cheaper(showtimes[i], showtimes[j]) })
+		spoken := showtimesPerCinema
+		if spoken > len(showtimes) {
+			spoken = len(showtimes)
+		}
+		earlier(showtimes[:spoken])
+		earlier(showtimes[spoken:])
+	}
+}
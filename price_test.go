@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePrice(t *testing.T) {
+	var td = []struct {
+		Raw string
+		Min int
+		Max int
+	}{
+		{"350", 350, 350},
+		{"от 250 ₽", 250, 250},
+		{"350–1 200 руб.", 350, 1200},
+		{"", 0, 0},
+	}
+	for _, tr := range td {
+		if min, max := ParsePrice(tr.Raw); min != tr.Min || max != tr.Max {
+			t.Errorf("wrong price of %q: %d-%d", tr.Raw, min, max)
+		}
+	}
+}
+
+func TestExtractPrice(t *testing.T) {
+	var td = []struct {
+		Phrase   string
+		MaxPrice int
+		Cheapest bool
+		Rest     string
+	}{
+		{"пассажир до 300 рублей", 300, false, "пассажир"},
+		{"а где подешевле", 0, true, "а"},
+		{"дюна не дороже 500", 500, false, "дюна"},
+		{"пассажир до 22:00", 0, false, "пассажир до 22:00"},
+	}
+	for _, tr := range td {
+		maxPrice, cheapest, rest := ExtractPrice(tr.Phrase)
+		if maxPrice != tr.MaxPrice || cheapest != tr.Cheapest || rest != tr.Rest {
+			t.Errorf("wrong price of %s: %d %v %s", tr.Phrase, maxPrice, cheapest, rest)
+		}
+	}
+}
+
+func TestCheapestShowtimes(t *testing.T) {
+	now := time.Date(2018, 3, 15, 12, 0, 0, 0, time.UTC)
+	result := &SearchResult{Movie: "Дюна", Cinemas: []Cinema{
+		{Name: "Октябрь", Showtimes: []Showtime{testPriced(18, "450"), testPriced(20, "550"), testPriced(21, "350")}},
+		{Name: "Пионер", Showtimes: []Showtime{testPriced(19, ""), testPriced(22, "300-400")}},
+	}}
+
+	window := NewShowtimeWindow(Entities{Date: now, MaxPrice: 500}, now)
+	cinemas := window.Filter(result)
+	if len(cinemas) != 2 || len(cinemas[0].Showtimes) != 2 || len(cinemas[1].Showtimes) != 1 {
+		t.Fatalf("wrong showtimes under 500: %v", cinemas)
+	}
+
+	cinemas = NewShowtimeWindow(Entities{Date: now}, now).Filter(result)
+	sortByPrice(cinemas)
+	// the cheapest showtimes are spoken in time order
+	expected := "В Пионер сеансы начинаются в 19:00 и в 22:00 от 300 рублей. В Октябрь в 18:00 за 450 рублей и в 21:00 за 350 рублей."
+	if phrase := describeCinemas(cinemas, true, testPhrases); phrase != expected {
+		t.Fatalf("wrong cheapest phrase: %s", phrase)
+	}
+}
@@ -0,0 +1,863 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const changeAddress = "Сменить адрес"
+const getAddress = "Мой адрес"
+const yes = "Да"
+const no = "Нет"
+
+// providers are not waited for longer than this, Alice doesn't wait for the answer long
+const searchBudget = 3 * time.Second
+
+var agreements = map[string]bool{
+	"да": true, "ага": true, "угу": true, "конечно": true, "верно": true,
+	"правильно": true, "именно": true, "точно": true, "да да": true, "да именно": true,
+}
+
+var refusals = map[string]bool{
+	"нет": true, "не": true, "неа": true, "нет нет": true, "не то": true,
+	"неправильно": true, "не этот": true, "другой": true,
+}
+
+// answerTags are answers said by the processor, every answers file must have them in the default locale
+var answerTags = []string{
+	"ASK_LOCATION", "UNKNOWN_LOCATION", "LOCATION_CONFIRMED", "CHANGE_ADDRESS",
+	"YOUR_ADDRESS", "WELCOME", "UNKNOWN_MOVIE", "DID_YOU_MEAN",
+	"WHICH_MOVIE", "ASK_MOVIE_AGAIN", "NO_SHOWTIMES", "NO_SHOWTIMES_IN_WINDOW",
+	"NO_SHOWTIMES_IN_FORMAT", "NO_SHOWTIMES_IN_PRICE", "SHOWTIMES_DAY", "SHOWTIMES_INTRO", "CHEAPEST_SHOWTIMES_INTRO",
+	"MORE_CINEMAS_HINT", "NO_MORE_CINEMAS", "HAS_MORE_CINEMAS", "NO_LATER_SHOWTIMES",
+	"NO_NEARBY_SHOWTIMES", "REPERTOIRE", "NO_REPERTOIRE", "CINEMA_SCHEDULE",
+	"NO_CINEMA_SHOWTIMES", "DID_YOU_MEAN_CINEMA", "ASK_CINEMA_AGAIN", "WHICH_LOCATION", "CONFIRM_LOCATION", "CORRECT_LOCATION",
+	"FORGOTTEN_LOCATION", "SYSTEM_ERROR",
+	// parts of answers
+	"FIRST_CINEMA_SHOWTIME", "FIRST_CINEMA_SHOWTIMES", "CINEMA_SHOWTIME", "CINEMA_SHOWTIMES",
+	"MOVIE_SHOWTIME", "MOVIE_SHOWTIMES", "SCREENING_NOW", "SCREENING_SOON", "SCREENING_AT", "PRICE", "PRICE_FROM",
+	"CINEMA_TICKET", "MOVIE_TICKET", "SCREENING_TICKET",
+}
+
+func isAgreement(phrase string) bool {
+	return agreements[strings.Join(splitWords(phrase), " ")]
+}
+
+func isRefusal(phrase string) bool {
+	return refusals[strings.Join(splitWords(phrase), " ")]
+}
+
+// MessageProcessor processes user phrases from Alice skill
+type MessageProcessor struct {
+	storage    Storage
+	parser     ShowtimeParser
+	catalog    *MovieCatalog
+	template   *Template
+	soonest    *Template
+	repertoire *Template
+	cinema     *Template
+	more       *Template
+	later      *Template
+	answers    *AnswerCatalog
+	machine    *StateMachine
+	images     ImageStore
+	subways    Subways
+	locator    *CinemaLocator
+	geocoder   Geocoder
+}
+
+// NewProcessor creates a new MessageProcessor with default templates.
+// Catalog is optional, without it movie titles are searched as is.
+func NewProcessor(storage Storage, parser ShowtimeParser, catalog *MovieCatalog) *MessageProcessor {
+	p := &MessageProcessor{
+		storage:    storage,
+		parser:     parser,
+		catalog:    catalog,
+		template:   Default(),
+		soonest:    SoonestTemplate(),
+		repertoire: RepertoireTemplate(),
+		cinema:     CinemaTemplate(),
+		more:       More(),
+		later:      Later(),
+		answers:    DefaultAnswers(),
+		subways:    DefaultSubways(),
+		geocoder:   DefaultGazetteer(),
+	}
+	p.machine = p.dialog()
+	return p
+}
+
+// SetAnswers replaces answers built into the skill
+func (p *MessageProcessor) SetAnswers(answers *AnswerCatalog) {
+	p.answers = answers
+}
+
+// SetGeocoder replaces the offline gazetteer which locates users by default
+func (p *MessageProcessor) SetGeocoder(geocoder Geocoder) {
+	p.geocoder = geocoder
+}
+
+// SetCinemaLocator enables ranking cinemas by distance, without a locator cinemas have no positions
+func (p *MessageProcessor) SetCinemaLocator(locator *CinemaLocator) {
+	p.locator = locator
+}
+
+// SetImageStore enables movie posters on cards, without a store cards have no images
+func (p *MessageProcessor) SetImageStore(images ImageStore) {
+	p.images = images
+}
+
+func (p *MessageProcessor) imageID(url string) string {
+	if p.images == nil || url == "" {
+		return ""
+	}
+	id, _ := p.images.ImageID(url)
+	return id
+}
+
+// dialog describes intents available in every state of the dialog
+func (p *MessageProcessor) dialog() *StateMachine {
+	buttons := []Intent{
+		{Name: "WELCOME", Matches: isPhrase(""), Handle: p.welcome},
+		{Name: "GET_ADDRESS", Matches: isPhrase(getAddress), Handle: p.tellAddress},
+		{Name: "CHANGE_ADDRESS", Matches: isPhrase(changeAddress), Handle: p.askNewAddress},
+	}
+	search := Intent{Name: "SEARCH_MOVIE", Handle: p.searchMovie}
+	followUp := Intent{Name: "FOLLOW_UP", Matches: isFollowUpPhrase, Handle: p.followUp}
+	cinema := Intent{Name: "CINEMA_SCHEDULE", Matches: p.isCinemaPhrase, Handle: p.cinemaSchedule}
+	repertoire := Intent{Name: "REPERTOIRE", Matches: p.isRepertoirePhrase, Handle: p.tellRepertoire}
+	soonest := Intent{Name: "SOONEST_SHOWTIMES", Matches: p.isSoonestPhrase, Handle: p.soonestShowtimes}
+
+	m := NewStateMachine()
+	m.On(StateOnboarding, Intent{Name: "ASK_LOCATION", Handle: p.askLocation})
+	m.On(StateAwaitingLocation, Intent{Name: "SAVE_LOCATION", Handle: p.saveLocation})
+	m.On(StateAwaitingLocationChoice,
+		Intent{Name: "CHOOSE_LOCATION", Matches: isLocationChoice, Handle: p.chooseLocation},
+		Intent{Name: "SAVE_LOCATION", Handle: p.saveLocation},
+	)
+	// only an agreement confirms the offered place, any other phrase is a corrected address
+	m.On(StateConfirmingLocation,
+		Intent{Name: "CONFIRM_LOCATION", Matches: isLocationAgreement, Handle: p.chooseLocation},
+		Intent{Name: "FORGOTTEN_LOCATION", Matches: isAgreementPhrase, Handle: p.forgottenLocation},
+		Intent{Name: "CORRECT_LOCATION", Matches: isRefusalPhrase, Handle: p.correctLocation},
+		Intent{Name: "SAVE_LOCATION", Handle: p.saveLocation},
+	)
+
+	m.On(StateIdle, buttons...)
+	m.On(StateIdle, soonest, repertoire, cinema, followUp, search)
+
+	m.On(StateAwaitingChoice, buttons...)
+	m.On(StateAwaitingChoice,
+		Intent{Name: "CHOOSE_MOVIE", Matches: isChoice, Handle: p.chooseMovie},
+		Intent{Name: "REFUSE_CHOICE", Matches: isRefusalPhrase, Handle: p.refuseChoice},
+		search,
+	)
+
+	m.On(StateConfirmingCinema, buttons...)
+	// without the offered cinema in the session the answer is handled as a new request
+	m.On(StateConfirmingCinema,
+		Intent{Name: "CONFIRM_CINEMA", Matches: all(hasPendingCinema, isAgreementPhrase), Handle: p.confirmCinema},
+		Intent{Name: "REFUSE_CINEMA", Matches: all(hasPendingCinema, isRefusalPhrase), Handle: p.refuseCinema},
+		Intent{Name: "ANOTHER_REQUEST", Handle: p.anotherRequest},
+	)
+
+	m.On(StateBrowsing, buttons...)
+	m.On(StateBrowsing,
+		Intent{Name: "MORE_CINEMAS", Matches: p.matches(p.more, hasResults), Handle: p.moreCinemas},
+		Intent{Name: "LATER_SHOWTIMES", Matches: p.matches(p.later, hasResults), Handle: p.laterShowtimes},
+		soonest,
+		repertoire,
+		cinema,
+		followUp,
+		search,
+	)
+	return m
+}
+
+// Process processes through state machine logic an retrieves intents from user's phrases
+func (p *MessageProcessor) Process(aliceRequest *AliceRequest) *AliceResponse {
+	userID, locale := aliceRequest.Session.UserID, aliceRequest.Meta.Locale
+	timezone, _ := time.LoadLocation(aliceRequest.Meta.Timezone)
+
+	session := aliceRequest.Session
+
+	location, err := p.storage.Get(userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load data from storage: %v", err)
+		return say(session, p.answers.Answer(locale, "SYSTEM_ERROR", AnswerData{}))
+	}
+	state, err := p.storage.GetSession(session.SessionID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load a session: %v", err)
+		return say(session, p.answers.Answer(locale, "SYSTEM_ERROR", AnswerData{}))
+	}
+
+	phrase := aliceRequest.Request.Command
+
+	log.Printf("[INFO] User %s says: %s", userID, phrase)
+
+	ctx := &DialogContext{
+		Session:      session,
+		Phrase:       phrase,
+		Now:          time.Now().In(timezone),
+		Current:      currentState(location, session),
+		Location:     location,
+		SessionState: state,
+		Screen:       aliceRequest.Meta.Interfaces.Screen != nil,
+		Locale:       locale,
+	}
+	transition, ok := p.machine.Handle(ctx.Current, ctx)
+	if !ok {
+		log.Printf("[WARN] No intent for user %s in state %q", userID, ctx.Current)
+		return sayWithButtons(session, p.getAnswer(ctx, "UNKNOWN_MOVIE"))
+	}
+
+	if transition.To != location.State {
+		ctx.Location.State = transition.To
+		if err := p.storage.Save(userID, ctx.Location); err != nil {
+			log.Printf("[ERROR] Failed to save a user state: %v", err)
+			return say(session, p.getAnswer(ctx, "SYSTEM_ERROR"))
+		}
+	}
+	if ctx.sessionChanged {
+		if err := p.storage.SaveSession(session.SessionID, ctx.SessionState); err != nil {
+			log.Printf("[ERROR] Failed to save a session: %v", err)
+			return say(session, p.getAnswer(ctx, "SYSTEM_ERROR"))
+		}
+	}
+	return transition.Response
+}
+
+func isPhrase(expected string) func(ctx *DialogContext) bool {
+	return func(ctx *DialogContext) bool {
+		return ctx.Phrase == expected
+	}
+}
+
+// matches checks the phrase against the template and additional conditions
+func (p *MessageProcessor) matches(template *Template, conditions ...func(ctx *DialogContext) bool) func(ctx *DialogContext) bool {
+	return func(ctx *DialogContext) bool {
+		for _, condition := range conditions {
+			if !condition(ctx) {
+				return false
+			}
+		}
+		_, ok := template.Matches(strings.Replace(strings.ToLower(ctx.Phrase), "ё", "е", -1))
+		return ok
+	}
+}
+
+func isChoice(ctx *DialogContext) bool {
+	_, ok := SelectChoice(ctx.Phrase, ctx.SessionState.PendingChoices)
+	return ok
+}
+
+func isLocationChoice(ctx *DialogContext) bool {
+	_, ok := SelectLocationChoice(ctx.Phrase, ctx.SessionState.PendingLocations)
+	return ok
+}
+
+// isFollowUpPhrase checks if the phrase only changes constraints of the last search: "а завтра?"
+func isFollowUpPhrase(ctx *DialogContext) bool {
+	if ctx.SessionState.Query == nil {
+		return false
+	}
+	entities, rest := ExtractEntities(strings.ToLower(ctx.Phrase), ctx.Now)
+	return !entities.IsEmpty() && IsFollowUp(rest)
+}
+
+func (p *MessageProcessor) isSoonestPhrase(ctx *DialogContext) bool {
+	if p.catalog == nil {
+		return false
+	}
+	_, rest := ExtractEntities(strings.ToLower(ctx.Phrase), ctx.Now)
+	_, ok := p.soonest.Matches(rest)
+	return ok
+}
+
+func (p *MessageProcessor) isRepertoirePhrase(ctx *DialogContext) bool {
+	if p.catalog == nil {
+		return false
+	}
+	_, rest := ExtractEntities(strings.ToLower(ctx.Phrase), ctx.Now)
+	_, ok := p.repertoire.Matches(rest)
+	return ok
+}
+
+func (p *MessageProcessor) isCinemaPhrase(ctx *DialogContext) bool {
+	_, _, _, ok := p.resolveCinema(ctx)
+	return ok
+}
+
+// isLocationAgreement checks if the user agrees with the offered place, it is lost with an unsaved session
+func isLocationAgreement(ctx *DialogContext) bool {
+	return len(ctx.SessionState.PendingLocations) > 0 && isAgreement(ctx.Phrase)
+}
+
+func hasPendingCinema(ctx *DialogContext) bool {
+	return ctx.SessionState.PendingCinema != nil
+}
+
+// all checks that every condition holds
+func all(conditions ...func(ctx *DialogContext) bool) func(ctx *DialogContext) bool {
+	return func(ctx *DialogContext) bool {
+		for _, condition := range conditions {
+			if !condition(ctx) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func isAgreementPhrase(ctx *DialogContext) bool {
+	return isAgreement(ctx.Phrase)
+}
+
+func isRefusalPhrase(ctx *DialogContext) bool {
+	return isRefusal(ctx.Phrase)
+}
+
+// stay keeps the dialog in the current state
+func stay(ctx *DialogContext, response *AliceResponse) Transition {
+	return Transition{ctx.Current, response}
+}
+
+// if location is unknown, we have to retrieve it from user
+func (p *MessageProcessor) askLocation(ctx *DialogContext) Transition {
+	return Transition{StateAwaitingLocation, say(ctx.Session, p.getAnswer(ctx, "ASK_LOCATION"))}
+}
+
+func (p *MessageProcessor) saveLocation(ctx *DialogContext) Transition {
+	newLocation, err := p.geocoder.Locate(ctx.Phrase)
+	if ambiguous, ok := err.(AmbiguousLocationError); ok {
+		return p.askLocationChoice(ctx, ambiguous.Locations)
+	}
+	if err != nil {
+		if err == UnknownLocationError {
+			return stay(ctx, say(ctx.Session, p.getAnswer(ctx, "UNKNOWN_LOCATION")))
+		}
+		log.Printf("[ERROR] failed to locate the user: %v", err)
+		return stay(ctx, say(ctx.Session, p.getAnswer(ctx, "SYSTEM_ERROR")))
+	}
+
+	// the address is saved only after the user agrees it was understood right
+	ctx.SessionState.PendingLocations = []Location{*newLocation}
+	ctx.SessionChanged()
+	answer := p.formatAnswer(ctx, "CONFIRM_LOCATION", AnswerData{Address: locationLabel(*newLocation)})
+	return Transition{StateConfirmingLocation, sayWithChoices(ctx.Session, answer, yes, no)}
+}
+
+// the understood address is wrong, the user is asked to say it again
+func (p *MessageProcessor) correctLocation(ctx *DialogContext) Transition {
+	ctx.SessionState.PendingLocations = nil
+	ctx.SessionChanged()
+	return Transition{StateAwaitingLocation, say(ctx.Session, p.getAnswer(ctx, "CORRECT_LOCATION"))}
+}
+
+// the user agrees with a place which is not in the session anymore, the address is asked again
+func (p *MessageProcessor) forgottenLocation(ctx *DialogContext) Transition {
+	return Transition{StateAwaitingLocation, say(ctx.Session, p.getAnswer(ctx, "FORGOTTEN_LOCATION"))}
+}
+
+// askLocationChoice remembers places in the session and asks the user which one was meant
+func (p *MessageProcessor) askLocationChoice(ctx *DialogContext, locations []Location) Transition {
+	ctx.SessionState.PendingLocations = locations
+	ctx.SessionChanged()
+
+	labels := locationLabels(locations)
+	answer := p.formatAnswer(ctx, "WHICH_LOCATION", AnswerData{Choices: strings.Join(labels, "; ")})
+	return Transition{StateAwaitingLocationChoice, sayWithChoices(ctx.Session, answer, labels...)}
+}
+
+// the previous answer was a question which place the user meant or if the single place is right
+func (p *MessageProcessor) chooseLocation(ctx *DialogContext) Transition {
+	index, _ := SelectLocationChoice(ctx.Phrase, ctx.SessionState.PendingLocations)
+	return p.rememberLocation(ctx, ctx.SessionState.PendingLocations[index])
+}
+
+// rememberLocation saves the location of the user, offered places are not needed anymore
+func (p *MessageProcessor) rememberLocation(ctx *DialogContext, newLocation Location) Transition {
+	if ctx.SessionState.PendingLocations != nil {
+		ctx.SessionState.PendingLocations = nil
+		ctx.SessionChanged()
+	}
+	ctx.Location.City = newLocation.City
+	ctx.Location.Region = newLocation.Region
+	ctx.Location.Subway = newLocation.Subway
+	ctx.Location.Position = newLocation.Position
+	answer := p.formatAnswer(ctx, "LOCATION_CONFIRMED", AnswerData{City: newLocation.City, Subway: newLocation.Subway})
+	return Transition{StateIdle, say(ctx.Session, answer)}
+}
+
+func (p *MessageProcessor) welcome(ctx *DialogContext) Transition {
+	return Transition{StateIdle, sayWithButtons(ctx.Session, p.getAnswer(ctx, "WELCOME"))}
+}
+
+func (p *MessageProcessor) tellAddress(ctx *DialogContext) Transition {
+	address := p.formatAnswer(ctx, "YOUR_ADDRESS", AnswerData{City: ctx.Location.City, Subway: ctx.Location.Subway})
+	return stay(ctx, sayWithButtons(ctx.Session, address))
+}
+
+func (p *MessageProcessor) askNewAddress(ctx *DialogContext) Transition {
+	return Transition{StateAwaitingLocation, say(ctx.Session, p.getAnswer(ctx, "CHANGE_ADDRESS"))}
+}
+
+// the previous answer was a question which movie the user meant
+func (p *MessageProcessor) chooseMovie(ctx *DialogContext) Transition {
+	index, _ := SelectChoice(ctx.Phrase, ctx.SessionState.PendingChoices)
+	movie, entities := ctx.SessionState.PendingChoices[index], ctx.SessionState.PendingEntities
+	ctx.SessionState.PendingChoices = nil
+	ctx.SessionChanged()
+
+	log.Printf("[INFO] User %s has chosen %s", ctx.Session.UserID, movie.Title)
+	return p.answerShowtimes(ctx, movie, entities)
+}
+
+func (p *MessageProcessor) refuseChoice(ctx *DialogContext) Transition {
+	ctx.SessionState.PendingChoices = nil
+	ctx.SessionChanged()
+	return Transition{StateIdle, sayWithButtons(ctx.Session, p.getAnswer(ctx, "ASK_MOVIE_AGAIN"))}
+}
+
+// searchMovie extracts a movie title and showtime constraints from the phrase and searches showtimes
+func (p *MessageProcessor) searchMovie(ctx *DialogContext) Transition {
+	if len(ctx.SessionState.PendingChoices) != 0 {
+		// a new request instead of an answer to the question
+		ctx.SessionState.PendingChoices = nil
+		ctx.SessionChanged()
+	}
+
+	entities, rest := ExtractEntities(strings.ToLower(ctx.Phrase), ctx.Now)
+	extracted, ok := p.template.Matches(rest)
+	if !ok {
+		return Transition{StateIdle, sayWithButtons(ctx.Session, p.getAnswer(ctx, "UNKNOWN_MOVIE"))}
+	}
+	movie, ok := extracted["movie"]
+	if !ok || movie == "" {
+		return Transition{StateIdle, sayWithButtons(ctx.Session, p.getAnswer(ctx, "UNKNOWN_MOVIE"))}
+	}
+
+	if p.catalog != nil {
+		matches, score, ok := p.catalog.Resolve(ctx.Location.City, movie)
+		log.Printf("[INFO] User %s movie %s matched to %d movies with score %.2f", ctx.Session.UserID, movie, len(matches), score)
+		if ok && score >= confidentMatch && len(matches) == 1 {
+			movie = matches[0].Title
+		} else if ok && score >= possibleMatch {
+			// the user chooses among equally good matches
+			return p.askChoice(ctx, matches, entities)
+		}
+	}
+
+	return p.answerShowtimes(ctx, Movie{Title: movie}, entities)
+}
+
+// followUp repeats the last search with constraints changed by the phrase
+func (p *MessageProcessor) followUp(ctx *DialogContext) Transition {
+	query := ctx.SessionState.Query
+	entities, _ := ExtractEntities(strings.ToLower(ctx.Phrase), ctx.Now)
+	if query.Cinema != nil {
+		log.Printf("[INFO] User %s follow-up for cinema %s", ctx.Session.UserID, query.Cinema.Name)
+		return p.answerCinema(ctx, *query.Cinema, entities.Refine(query.Entities))
+	}
+	log.Printf("[INFO] User %s follow-up for movie %s", ctx.Session.UserID, query.Movie.Title)
+	return p.answerShowtimes(ctx, query.Movie, entities.Refine(query.Entities))
+}
+
+// tellRepertoire lists popular movies of the city, buttons of movies start the search
+func (p *MessageProcessor) tellRepertoire(ctx *DialogContext) Transition {
+	movies := p.catalog.Repertoire(ctx.Location.City, repertoireSize)
+	if len(movies) == 0 {
+		return Transition{StateIdle, sayWithButtons(ctx.Session, p.getAnswer(ctx, "NO_REPERTOIRE"))}
+	}
+	titles := make([]string, 0, len(movies))
+	for _, movie := range movies {
+		titles = append(titles, movie.Title)
+	}
+	answer := p.formatAnswer(ctx, "REPERTOIRE", AnswerData{Movies: "«" + strings.Join(titles, "», «") + "»"})
+	return Transition{StateIdle, sayWithChoices(ctx.Session, answer, titles...)}
+}
+
+// soonestShowtimes tells which movies start soon in cinemas near the user
+func (p *MessageProcessor) soonestShowtimes(ctx *DialogContext) Transition {
+	entities, _ := ExtractEntities(strings.ToLower(ctx.Phrase), ctx.Now)
+	cinemaParser, ok := p.parser.(CinemaParser)
+	if !ok {
+		return Transition{StateIdle, sayWithButtons(ctx.Session, p.getAnswer(ctx, "NO_NEARBY_SHOWTIMES"))}
+	}
+	travel := p.subways.Travel(ctx.Location)
+	cinemas := nearbyCinemas(p.locator.Locate(ctx.Location.City, p.catalog.Cinemas(ctx.Location.City)), travel)
+	schedules := loadSchedules(cinemaParser, cinemas, ctx.Location.City, entities.Date)
+	for i, schedule := range schedules {
+		schedules[i] = travel.ReachableSchedule(schedule, ctx.Now)
+	}
+	screenings := soonestScreenings(schedules, NewShowtimeWindow(entities, ctx.Now), soonestCount)
+	log.Printf("[INFO] User %s found %d soonest showtimes in %d cinemas", ctx.Session.UserID, len(screenings), len(cinemas))
+	if len(screenings) == 0 {
+		return Transition{StateIdle, sayWithButtons(ctx.Session, p.getAnswer(ctx, "NO_NEARBY_SHOWTIMES"))}
+	}
+
+	titles := make([]string, 0, len(screenings))
+	for _, screening := range screenings {
+		titles = append(titles, screening.Movie)
+	}
+	response := sayWithChoices(ctx.Session, describeScreenings(screenings, ctx.Now, p.phrases(ctx)), titles...)
+	return Transition{StateIdle, withTickets(response, screeningTicketButtons(screenings, p.phrases(ctx)))}
+}
+
+// resolveCinema finds a known cinema of the user's city in the phrase and returns it with a confidence
+func (p *MessageProcessor) resolveCinema(ctx *DialogContext) (Cinema, Entities, float64, bool) {
+	if p.catalog == nil {
+		return Cinema{}, Entities{}, 0, false
+	}
+	entities, rest := ExtractEntities(strings.ToLower(ctx.Phrase), ctx.Now)
+	extracted, ok := p.cinema.Matches(rest)
+	if !ok {
+		return Cinema{}, Entities{}, 0, false
+	}
+	cinema, score, ok := p.catalog.ResolveCinema(ctx.Location.City, extracted["cinema"])
+	if !ok || score < possibleMatch {
+		return Cinema{}, Entities{}, 0, false
+	}
+	return cinema, entities, score, true
+}
+
+// cinemaSchedule answers which movies are showing in the cinema from the phrase,
+// a cinema with a similar name is offered to the user first
+func (p *MessageProcessor) cinemaSchedule(ctx *DialogContext) Transition {
+	cinema, entities, score, _ := p.resolveCinema(ctx)
+	log.Printf("[INFO] User %s asked for cinema %s with score %.2f", ctx.Session.UserID, cinema.Name, score)
+	if score < confidentMatch {
+		ctx.SessionState.PendingCinema = &cinema
+		ctx.SessionState.PendingEntities = entities
+		ctx.SessionChanged()
+		answer := p.formatAnswer(ctx, "DID_YOU_MEAN_CINEMA", AnswerData{Cinema: cinema.Name})
+		return Transition{StateConfirmingCinema, sayWithChoices(ctx.Session, answer, yes, no)}
+	}
+	return p.answerCinema(ctx, cinema, entities)
+}
+
+// the previous answer was a question if the user meant the similar cinema
+func (p *MessageProcessor) confirmCinema(ctx *DialogContext) Transition {
+	cinema, entities := *ctx.SessionState.PendingCinema, ctx.SessionState.PendingEntities
+	ctx.SessionState.PendingCinema = nil
+	ctx.SessionChanged()
+	return p.answerCinema(ctx, cinema, entities)
+}
+
+func (p *MessageProcessor) refuseCinema(ctx *DialogContext) Transition {
+	ctx.SessionState.PendingCinema = nil
+	ctx.SessionChanged()
+	return Transition{StateIdle, sayWithButtons(ctx.Session, p.getAnswer(ctx, "ASK_CINEMA_AGAIN"))}
+}
+
+// anotherRequest forgets the offered cinema and handles the phrase as a new request
+func (p *MessageProcessor) anotherRequest(ctx *DialogContext) Transition {
+	ctx.SessionState.PendingCinema = nil
+	ctx.SessionChanged()
+	ctx.Current = StateIdle
+	transition, _ := p.machine.Handle(StateIdle, ctx)
+	return transition
+}
+
+// answerCinema loads the schedule of the cinema and constructs an answer for the user
+func (p *MessageProcessor) answerCinema(ctx *DialogContext, cinema Cinema, entities Entities) Transition {
+	cinemaParser, ok := p.parser.(CinemaParser)
+	if !ok {
+		return Transition{StateIdle, sayWithButtons(ctx.Session, p.formatAnswer(ctx, "NO_CINEMA_SHOWTIMES", AnswerData{Cinema: cinema.Name}))}
+	}
+	schedule, err := cinemaParser.GetCinemaShowtimes(cinema, ctx.Location.City, entities.Date)
+	if err != nil {
+		log.Printf("[ERROR] failed to load cinema schedule: %v", err)
+		return Transition{StateIdle, sayWithButtons(ctx.Session, p.getAnswer(ctx, "SYSTEM_ERROR"))}
+	}
+
+	ctx.SessionState.Query = &Query{Cinema: &cinema, Entities: entities}
+	ctx.SessionState.Results = nil
+	ctx.SessionChanged()
+
+	movies := NewShowtimeWindow(entities, ctx.Now).FilterSchedule(schedule)
+	if len(movies) == 0 {
+		return Transition{StateIdle, sayWithButtons(ctx.Session, p.formatAnswer(ctx, "NO_CINEMA_SHOWTIMES", AnswerData{Cinema: cinema.Name}))}
+	}
+	day := formatDay(entities.Date, ctx.Now)
+	answer := p.formatAnswer(ctx, "CINEMA_SCHEDULE", AnswerData{Cinema: cinema.Name, Day: day, Showtimes: describeSchedule(movies, p.phrases(ctx))})
+	response := withTickets(sayWithButtons(ctx.Session, answer), scheduleTicketButtons(movies, p.phrases(ctx)))
+	if ctx.Screen {
+		response.Response.Card = scheduleCard(cinema.Name, day, movies)
+	}
+	return Transition{StateBrowsing, response}
+}
+
+// askChoice remembers movies in the session and asks the user which one was meant
+func (p *MessageProcessor) askChoice(ctx *DialogContext, movies []Movie, entities Entities) Transition {
+	if len(movies) > maxChoices {
+		movies = movies[:maxChoices]
+	}
+	ctx.SessionState.PendingChoices = movies
+	ctx.SessionState.PendingEntities = entities
+	ctx.SessionChanged()
+
+	if len(movies) == 1 {
+		answer := p.formatAnswer(ctx, "DID_YOU_MEAN", AnswerData{Movie: choiceLabel(movies[0])})
+		return Transition{StateAwaitingChoice, sayWithChoices(ctx.Session, answer, yes, no)}
+	}
+	labels := choiceLabels(movies)
+	answer := p.formatAnswer(ctx, "WHICH_MOVIE", AnswerData{Choices: "«" + strings.Join(labels, "», «") + "»"})
+	return Transition{StateAwaitingChoice, sayWithChoices(ctx.Session, answer, labels...)}
+}
+
+// answerShowtimes searches showtimes of the movie and constructs an answer for the user
+func (p *MessageProcessor) answerShowtimes(ctx *DialogContext, movie Movie, entities Entities) Transition {
+	session, currentTime := ctx.Session, ctx.Now
+	searchResult, err := p.searchShowtimes(movie, ctx.Location, entities.Date)
+
+	if err != nil {
+		if err == NoSuchMovie {
+			return Transition{StateIdle, sayWithButtons(session, p.getAnswer(ctx, "UNKNOWN_MOVIE"))}
+		}
+		if ambiguous, ok := err.(AmbiguousMovieError); ok {
+			return p.askChoice(ctx, ambiguous.Movies, entities)
+		}
+		log.Printf("[ERROR] failed to load showtimes: %v", err)
+		return stay(ctx, sayTerminal(session, p.getAnswer(ctx, "SYSTEM_ERROR")))
+	}
+	log.Printf("[INFO] User %s found cinemas with movie %s on %s: %d", session.UserID, searchResult.Movie, entities.Date.Format("2006-01-02"), len(searchResult.Cinemas))
+	// follow-up questions search the found movie, not the one said by the user
+	if movie.Provider == "" {
+		movie.Title = searchResult.Movie
+	}
+	ctx.SessionState.Query = &Query{Movie: movie, Entities: entities}
+	ctx.SessionState.Results = nil
+	ctx.SessionChanged()
+	// showtimes starting before the user gets to the cinema are useless
+	travel := p.subways.Travel(ctx.Location)
+	searchResult.Cinemas = travel.Reachable(p.locator.Locate(ctx.Location.City, searchResult.Cinemas), currentTime)
+	if isNoShowtimes(searchResult) {
+		return Transition{StateIdle, sayWithButtons(session, p.getAnswer(ctx, "NO_SHOWTIMES"))}
+	}
+	window := NewShowtimeWindow(entities, currentTime)
+	showtimes := window.Filter(searchResult)
+	var intro string
+	if len(showtimes) == 0 {
+		// nothing in the asked time, but maybe there is something later
+		showtimes = window.Later(searchResult)
+		if len(showtimes) == 0 && entities.Format != 0 {
+			return Transition{StateIdle, sayWithButtons(session, p.getAnswer(ctx, "NO_SHOWTIMES_IN_FORMAT"))}
+		}
+		if len(showtimes) == 0 && entities.MaxPrice != 0 {
+			return Transition{StateIdle, sayWithButtons(session, p.getAnswer(ctx, "NO_SHOWTIMES_IN_PRICE"))}
+		}
+		if len(showtimes) == 0 {
+			return Transition{StateIdle, sayWithButtons(session, p.getAnswer(ctx, "NO_SHOWTIMES"))}
+		}
+		intro = p.getAnswer(ctx, "NO_SHOWTIMES_IN_WINDOW") + " "
+	}
+	showtimes = travel.Rank(showtimes)
+
+	results := NewResults(searchResult.Movie, entities.Date, showtimes)
+	results.Poster = searchResult.Poster
+	if entities.Cheapest {
+		sortByPrice(results.Cinemas)
+		results.ByPrice = true
+	}
+	page := results.NextPage()
+	answer := intro + p.constructShowtimesPhrase(ctx, results, page)
+	ctx.SessionState.Results = results
+	ctx.SessionChanged()
+	return Transition{StateBrowsing, p.showtimesResponse(ctx, answer, results, page)}
+}
+
+func hasResults(ctx *DialogContext) bool {
+	return ctx.SessionState.Results != nil
+}
+
+// moreCinemas continues the last answer with the next cinemas
+func (p *MessageProcessor) moreCinemas(ctx *DialogContext) Transition {
+	results := ctx.SessionState.Results
+	if !results.HasMore() {
+		return stay(ctx, sayWithButtons(ctx.Session, p.getAnswer(ctx, "NO_MORE_CINEMAS")))
+	}
+	page := results.NextPage()
+	answer := describeCinemas(page, results.ByPrice, p.phrases(ctx))
+	if results.HasMore() {
+		answer += " " + p.getAnswer(ctx, "HAS_MORE_CINEMAS")
+	}
+	ctx.SessionChanged()
+	return stay(ctx, p.showtimesResponse(ctx, answer, results, page))
+}
+
+// laterShowtimes repeats the last answer with showtimes after the already spoken ones
+func (p *MessageProcessor) laterShowtimes(ctx *DialogContext) Transition {
+	results := ctx.SessionState.Results
+	if !results.Later() {
+		return stay(ctx, sayWithButtons(ctx.Session, p.getAnswer(ctx, "NO_LATER_SHOWTIMES")))
+	}
+	ctx.SessionChanged()
+	page := results.NextPage()
+	return stay(ctx, p.showtimesResponse(ctx, p.constructShowtimesPhrase(ctx, results, page), results, page))
+}
+
+// showtimesResponse speaks a page of results with ticket buttons and shows a card on devices with a screen
+func (p *MessageProcessor) showtimesResponse(ctx *DialogContext, answer string, results *Results, page []Cinema) *AliceResponse {
+	response := withTickets(sayWithButtons(ctx.Session, answer), ticketButtons(page, p.phrases(ctx)))
+	if ctx.Screen {
+		response.Response.Card = showtimesCard(results.Movie, formatDay(results.Date, ctx.Now), page, p.imageID(results.Poster))
+	}
+	return response
+}
+
+// searchShowtimes loads showtimes of a movie chosen before or tries normalized variants
+// of the movie title until a provider knows one of them
+func (p *MessageProcessor) searchShowtimes(movie Movie, location *Location, date time.Time) (*SearchResult, error) {
+	if exact, ok := p.parser.(ExactShowtimeParser); ok && movie.Provider != "" {
+		return exact.GetMovieShowtimes(movie, location.City, location.Subway, date)
+	}
+	candidates := QueryCandidates(movie.Title)
+	// the catalog knows titles of the city, so providers are asked once
+	if p.catalog != nil && len(candidates) > 1 {
+		if matches, score, ok := p.catalog.Resolve(location.City, movie.Title); ok && score >= confidentMatch && len(matches) == 1 {
+			candidates = []string{matches[0].Title}
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), searchBudget)
+	defer cancel()
+	for i, candidate := range candidates {
+		if i > 0 && ctx.Err() != nil {
+			log.Printf("[WARN] No time left to search other forms of %s", movie.Title)
+			break
+		}
+		result, err := p.getShowtimes(ctx, candidate, location, date)
+		// the first form is not found, so running out of time on other forms means the movie is unknown
+		if err == NoSuchMovie || i > 0 && err == context.DeadlineExceeded {
+			log.Printf("[INFO] Movie %s not found, trying the next form", candidate)
+			continue
+		}
+		return result, err
+	}
+	return nil, NoSuchMovie
+}
+
+// getShowtimes asks the parser for showtimes, parsers with a context stop waiting for providers in time
+func (p *MessageProcessor) getShowtimes(ctx context.Context, title string, location *Location, date time.Time) (*SearchResult, error) {
+	if parser, ok := p.parser.(ContextShowtimeParser); ok {
+		return parser.GetShowtimesContext(ctx, title, location.City, location.Subway, date)
+	}
+	return p.parser.GetShowtimes(title, location.City, location.Subway, date)
+}
+
+// constructShowtimesPhrase speaks a page of results with an introduction
+func (p *MessageProcessor) constructShowtimesPhrase(ctx *DialogContext, results *Results, page []Cinema) string {
+	var phrase string
+	if !results.Date.Equal(startOfDay(ctx.Now)) {
+		phrase = p.formatAnswer(ctx, "SHOWTIMES_DAY", AnswerData{Day: formatDay(results.Date, ctx.Now)}) + " "
+	}
+	if len(results.Cinemas) > cinemasPerPage {
+		// lots of cinemas nearby case
+		intro := "SHOWTIMES_INTRO"
+		if results.ByPrice {
+			intro = "CHEAPEST_SHOWTIMES_INTRO"
+		}
+		phrase += p.formatAnswer(ctx, intro, AnswerData{Count: cinemasPerPage}) + " "
+	}
+	phrase += describeCinemas(page, results.ByPrice, p.phrases(ctx))
+	if results.HasMore() {
+		phrase += " " + p.getAnswer(ctx, "MORE_CINEMAS_HINT")
+	}
+	return phrase
+}
+
+var monthNames = []string{"", "января", "февраля", "марта", "апреля", "мая", "июня",
+	"июля", "августа", "сентября", "октября", "ноября", "декабря"}
+
+// formatDay returns a human readable day relative to user time, like "завтра" or "15 марта"
+func formatDay(date, userTime time.Time) string {
+	switch days := int(date.Sub(startOfDay(userTime)).Hours() / 24); days {
+	case 0:
+		return "сегодня"
+	case 1:
+		return "завтра"
+	case 2:
+		return "послезавтра"
+	}
+	return strconv.Itoa(date.Day()) + " " + monthNames[date.Month()]
+}
+
+func isNoShowtimes(search *SearchResult) bool {
+	if search == nil {
+		return true
+	}
+	for _, cinema := range search.Cinemas {
+		if len(cinema.Showtimes) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func sayWithButtons(session Session, phrase string) *AliceResponse {
+	response := say(session, phrase)
+	response.Response.Buttons = []Button{
+		Button{
+			Title: "Мой адрес",
+			Hide:  true,
+		},
+		Button{
+			Title: "Сменить адрес",
+			Hide:  true,
+		},
+	}
+	return response
+
+}
+
+// sayWithChoices shows choices as buttons which disappear after the user answers
+func sayWithChoices(session Session, phrase string, choices ...string) *AliceResponse {
+	response := say(session, phrase)
+	response.Response.Buttons = make([]Button, 0, len(choices))
+	for _, choice := range choices {
+		response.Response.Buttons = append(response.Response.Buttons, Button{
+			Title: choice,
+			Hide:  true,
+		})
+	}
+	return response
+}
+
+func say(session Session, phrase string) *AliceResponse {
+	response := getResponseStub(session)
+	response.Response.Tts = RenderTTS(phrase)
+	response.Response.Text = phrase
+	return response
+}
+
+func sayTerminal(session Session, phrase string) *AliceResponse {
+	response := say(session, phrase)
+	response.Response.EndSession = true
+	return response
+}
+
+func getResponseStub(session Session) *AliceResponse {
+	return &AliceResponse{
+		Version: "1.0",
+		Session: session,
+	}
+}
+
+func (p *MessageProcessor) getAnswer(ctx *DialogContext, tag string) string {
+	return p.formatAnswer(ctx, tag, AnswerData{})
+}
+
+// formatAnswer says the answer in the locale of the user with placeholders filled from data
+func (p *MessageProcessor) formatAnswer(ctx *DialogContext, tag string, data AnswerData) string {
+	return p.answers.Answer(ctx.Locale, tag, data)
+}
+
+func (p *MessageProcessor) phrases(ctx *DialogContext) Phrases {
+	return func(tag string, data AnswerData) string {
+		return p.formatAnswer(ctx, tag, data)
+	}
+}
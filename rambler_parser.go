@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,25 +15,39 @@ import (
 )
 
 const ramblerSearchTemplate = "https://kassa.rambler.ru/search?search_str=%s"
+const ramblerMoviesTemplate = "https://kassa.rambler.ru/%s/movies"
+const ramblerCinemasTemplate = "https://kassa.rambler.ru/%s/cinemas"
+const ramblerHost = "https://kassa.rambler.ru"
+const ramblerTicketTemplate = "https://kassa.rambler.ru/place/hallplan?sessionid=%s"
+const ramblerDateFormat = "2006.01.02"
 const mskName = "москва"
 const spbName = "санкт-петербург"
 const nnName = "нижний новгород"
 
-// NoSuchMovie fired when movie with given name is not found
-var NoSuchMovie = NoSuchMovieError{}
-
-var httpClient = &http.Client{}
-
 // RamblerSearch contains info about matching movies
 type RamblerSearch struct {
-	Items []struct {
-		Link string
-		Name string
-	}
+	Items []ramblerItem
 }
 
-// GetRamblerShowtimes retrieves showtimes info about the movie in provided region with sort logic based on user time
-func GetRamblerShowtimes(movieName, city, region string, timezone *time.Location) (*SearchResult, error) {
+type ramblerItem struct {
+	Link string
+	Name string
+	Year int
+}
+
+// items with scores that differ less than this are equally good for the user query
+const ambiguityDelta = 0.05
+
+// RamblerParser is a ShowtimeParser backed by kassa.rambler.ru
+type RamblerParser struct{}
+
+// GetShowtimes implements ShowtimeParser
+func (RamblerParser) GetShowtimes(movieName, city, region string, date time.Time) (*SearchResult, error) {
+	return GetRamblerShowtimes(movieName, city, region, date)
+}
+
+// GetRamblerShowtimes retrieves showtimes info about the movie in all cinemas of the city for the given day
+func GetRamblerShowtimes(movieName, city, region string, date time.Time) (*SearchResult, error) {
 	searchRes, err := getMovieDesciptions(movieName)
 	if err != nil {
 		return nil, err
@@ -40,41 +55,194 @@ func GetRamblerShowtimes(movieName, city, region string, timezone *time.Location
 	if len(searchRes.Items) == 0 {
 		return nil, NoSuchMovie
 	}
-	name := searchRes.Items[0].Name
-	link := formatLink(searchRes.Items[0].Link, city)
-	cinemas, err := getMovieShowtimes(link, city, region, timezone)
+	items := searchRes.bestMatches(movieName)
+	if len(items) > 1 {
+		movies := make([]Movie, 0, len(items))
+		for _, item := range items {
+			movies = append(movies, Movie{Title: item.Name, Year: item.Year, Link: item.Link})
+		}
+		return nil, AmbiguousMovieError{movies}
+	}
+	return getRamblerMovieShowtimes(Movie{Title: items[0].Name, Year: items[0].Year, Link: items[0].Link}, city, date)
+}
+
+// GetMovieShowtimes implements ExactShowtimeParser
+func (RamblerParser) GetMovieShowtimes(movie Movie, city, region string, date time.Time) (*SearchResult, error) {
+	return getRamblerMovieShowtimes(movie, city, date)
+}
+
+func getRamblerMovieShowtimes(movie Movie, city string, date time.Time) (*SearchResult, error) {
+	cinemas, poster, err := getMovieShowtimes(formatLink(movie.Link, city, date), date)
 	if err != nil {
 		return nil, err
 	}
 
 	return &SearchResult{
-		Movie:   name,
+		Movie:   movie.Title,
 		Cinemas: cinemas,
+		Poster:  poster,
+		Year:    movie.Year,
 	}, nil
 }
 
-func formatLink(link, city string) string {
-	cityCode := ""
+// bestMatches returns search items with titles closest to the query, rambler search is quite fuzzy itself.
+// Several items are returned only if they match the query equally well: remakes, same-name titles, etc.
+func (s *RamblerSearch) bestMatches(movieName string) []ramblerItem {
+	scores := make([]float64, len(s.Items))
+	bestScore := 0.0
+	for i, item := range s.Items {
+		scores[i] = MatchScore(movieName, item.Name)
+		if scores[i] > bestScore {
+			bestScore = scores[i]
+		}
+	}
+	if bestScore < possibleMatch {
+		// trust rambler search if nothing looks similar, it may know aliases
+		return s.Items[:1]
+	}
+
+	matches := make([]ramblerItem, 0)
+	for i, item := range s.Items {
+		if scores[i] >= bestScore-ambiguityDelta {
+			matches = append(matches, item)
+		}
+	}
+	return matches
+}
+
+// GetMovies implements MovieLister
+func (RamblerParser) GetMovies(city string) ([]Movie, error) {
+	raw, err := fetchPage(fmt.Sprintf(ramblerMoviesTemplate, cityCode(city)))
+	if err != nil {
+		return nil, err
+	}
+	root := soup.HTMLParse(raw)
+
+	movies := make([]Movie, 0)
+	for _, item := range root.FindAll("div", "class", "movie_item") {
+		nameBlock := item.Find("a", "class", "s-name")
+		if nameBlock.Error != nil {
+			continue
+		}
+		title := strings.TrimSpace(nameBlock.Text())
+		if title == "" {
+			continue
+		}
+		movie := Movie{Title: title}
+		if sessionsBlock := item.Find("span", "class", "s-sessions"); sessionsBlock.Error == nil {
+			movie.Showtimes = parseCount(sessionsBlock.Text())
+		}
+		movies = append(movies, movie)
+	}
+	return movies, nil
+}
+
+// GetCinemas implements CinemaParser
+func (RamblerParser) GetCinemas(city string) ([]Cinema, error) {
+	raw, err := fetchPage(fmt.Sprintf(ramblerCinemasTemplate, cityCode(city)))
+	if err != nil {
+		return nil, err
+	}
+	root := soup.HTMLParse(raw)
+
+	cinemas := make([]Cinema, 0)
+	for _, item := range root.FindAll("div", "class", "place_item") {
+		nameBlock := item.Find("a", "class", "s-name")
+		if nameBlock.Error != nil {
+			continue
+		}
+		name := strings.TrimSpace(nameBlock.Text())
+		link := nameBlock.Attrs()["href"]
+		if name == "" || link == "" {
+			continue
+		}
+		cinema := Cinema{Name: name, Link: link}
+		if addressBlock := item.Find("div", "class", "place_address"); addressBlock.Error == nil {
+			cinema.Address = strings.TrimSpace(addressBlock.Text())
+		}
+		if subwayBlock := item.Find("div", "class", "rasp_place_metro"); subwayBlock.Error == nil {
+			cinema.Subway = strings.TrimSpace(subwayBlock.Text())
+		}
+		cinemas = append(cinemas, cinema)
+	}
+	return cinemas, nil
+}
+
+// GetCinemaShowtimes implements CinemaParser
+func (RamblerParser) GetCinemaShowtimes(cinema Cinema, city string, date time.Time) (*CinemaSchedule, error) {
+	link := cinema.Link
+	if strings.HasPrefix(link, "/") {
+		link = ramblerHost + link
+	}
+	raw, err := fetchPage(link + "?date=" + date.Format(ramblerDateFormat))
+	if err != nil {
+		return nil, err
+	}
+	root := soup.HTMLParse(raw)
+
+	schedule := &CinemaSchedule{Cinema: cinema, Movies: make([]MovieShowtimes, 0)}
+	// the cinema page has the same schedule blocks as the movie page, but with movie titles
+	for _, item := range root.FindAll("div", "class", "rasp_item_in") {
+		titleBlock := item.Find("div", "class", "rasp_title").Find("span", "class", "s-name")
+		if titleBlock.Error != nil {
+			continue
+		}
+		scheduleBlock := item.Find("div", "class", "rasp_list")
+		if scheduleBlock.Error != nil {
+			continue
+		}
+		schedule.Movies = append(schedule.Movies, MovieShowtimes{
+			Movie:     strings.TrimSpace(titleBlock.Text()),
+			Showtimes: parseShowtimes(scheduleBlock, date),
+		})
+	}
+	return schedule, nil
+}
+
+// parseCount returns the first number of a text like "128 сеансов"
+func parseCount(text string) int {
+	for _, field := range strings.Fields(text) {
+		if count, err := strconv.Atoi(field); err == nil {
+			return count
+		}
+	}
+	return 0
+}
+
+// showtimeFormat combines projection and language tags of a showtime button, like "IMAX 3D оригинал"
+func showtimeFormat(showtimeBlock soup.Root) string {
+	attrs := showtimeBlock.Attrs()
+	return strings.TrimSpace(attrs["data-format"] + " " + attrs["data-lang"])
+}
+
+func cityCode(city string) string {
 	city = strings.ToLower(city)
 	switch city {
 	case mskName:
-		cityCode = "msk"
+		return "msk"
 	case spbName:
-		cityCode = "spb"
+		return "spb"
 	case nnName:
-		cityCode = "nnovgorod"
+		return "nnovgorod"
 	default:
-		cityCode = strings.Replace(unidecode.Unidecode(city), " ", "-", -1)
+		return strings.Replace(unidecode.Unidecode(city), " ", "-", -1)
 	}
-	return strings.Replace(link, "movie/", cityCode+"/movie/", 1)
+}
+
+func formatLink(link, city string, date time.Time) string {
+	link = strings.Replace(link, "movie/", cityCode(city)+"/movie/", 1)
+	return link + "?date=" + date.Format(ramblerDateFormat)
 }
 
 func getMovieDesciptions(movieName string) (*RamblerSearch, error) {
-	resp, err := http.Get(fmt.Sprintf(ramblerSearchTemplate, url.QueryEscape(movieName)))
+	resp, err := httpClient.Get(fmt.Sprintf(ramblerSearchTemplate, url.QueryEscape(movieName)))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rambler search failed: %s", resp.Status)
+	}
 	var searchResult RamblerSearch
 	err = json.NewDecoder(resp.Body).Decode(&searchResult)
 	if err != nil {
@@ -83,13 +251,19 @@ func getMovieDesciptions(movieName string) (*RamblerSearch, error) {
 	return &searchResult, nil
 }
 
-func getMovieShowtimes(link, city, region string, timezone *time.Location) ([]Cinema, error) {
-	raw, err := soup.Get(link)
+// getMovieShowtimes parses cinemas and the poster of a movie page
+func getMovieShowtimes(link string, date time.Time) ([]Cinema, string, error) {
+	raw, err := fetchPage(link)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	root := soup.HTMLParse(raw)
 
+	var poster string
+	if image := root.Find("meta", "property", "og:image"); image.Error == nil {
+		poster = image.Attrs()["content"]
+	}
+
 	cinemas := make([]Cinema, 0)
 
 	for _, item := range root.FindAll("div", "class", "rasp_item_in") {
@@ -109,48 +283,39 @@ func getMovieShowtimes(link, city, region string, timezone *time.Location) ([]Ci
 			subway = subwayBlock.Text()
 		}
 
-		if region != "" {
-			cityLower := strings.ToLower(city)
-
-			// if user is from moscow of saint-petersburg, than follow a subway comparrison block
-			if cityLower == mskName || cityLower == spbName {
-				// if region is provided but there is no subway info for the cinema - skip it
-				if subway == "" {
-					continue
-				}
-				replacer := strings.NewReplacer("ё", "е",
-					"Ё", "Е")
-				if !strings.Contains(strings.ToLower(replacer.Replace(subway)), strings.ToLower(replacer.Replace(region))) {
-					continue
-				}
-			}
-		}
-
 		scheduleBlock := item.Find("div", "class", "rasp_list")
 		if scheduleBlock.Error != nil {
 			continue
 		}
-		showtimes := make([]Showtime, 0)
-		for _, showtimeBlock := range scheduleBlock.FindAll("li", "class", "btn_rasp") {
-			attrs := showtimeBlock.Attrs()["class"]
-			if strings.Contains(attrs, "inactive") {
-				// should skip showtimes that are in past
-				continue
-			}
-
-			if time, err := time.ParseInLocation("15:04", strings.TrimSpace(showtimeBlock.Text()), timezone); err == nil {
-				if time.Hour() == 0 || time.Hour() == 1 {
-					time = time.AddDate(0, 0, 1)
-				}
-				showtimes = append(showtimes, Showtime{Time: time})
-			}
-		}
 		cinemas = append(cinemas, Cinema{
 			Name:      cinemaName,
 			Address:   address,
 			Subway:    subway,
-			Showtimes: showtimes,
+			Showtimes: parseShowtimes(scheduleBlock, date),
 		})
 	}
-	return cinemas, nil
+	return cinemas, poster, nil
+}
+
+func parseShowtimes(scheduleBlock soup.Root, date time.Time) []Showtime {
+	showtimes := make([]Showtime, 0)
+	for _, showtimeBlock := range scheduleBlock.FindAll("li", "class", "btn_rasp") {
+		attrs := showtimeBlock.Attrs()["class"]
+		if strings.Contains(attrs, "inactive") {
+			// should skip showtimes that are in past
+			continue
+		}
+
+		if time, err := showtimeAt(date, showtimeBlock.Text()); err == nil {
+			showtime := Showtime{Time: time, Format: showtimeFormat(showtimeBlock)}
+			if priceBlock := showtimeBlock.Find("span", "class", "s-price"); priceBlock.Error == nil {
+				showtime.SetPrice(priceBlock.Text())
+			}
+			if sessionID := showtimeBlock.Attrs()["data-sessionid"]; sessionID != "" {
+				showtime.TicketURL = fmt.Sprintf(ramblerTicketTemplate, url.QueryEscape(sessionID))
+			}
+			showtimes = append(showtimes, showtime)
+		}
+	}
+	return showtimes
 }
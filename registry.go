@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// provider is a ShowtimeParser registered under some name with a priority
+type provider struct {
+	name     string
+	priority int
+	parser   ShowtimeParser
+}
+
+// ProviderRegistry keeps showtime providers ordered by priority.
+// It implements ShowtimeParser itself: by default providers are asked one by one
+// until one of them returns a non empty result. With a deadline set all providers
+// are asked concurrently and their results are merged.
+type ProviderRegistry struct {
+	providers []provider
+	deadline  time.Duration
+}
+
+// NewProviderRegistry creates an empty registry
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{providers: make([]provider, 0)}
+}
+
+// SetDeadline switches the registry to a concurrent fan-out mode.
+// Providers that did not answer within the deadline are ignored. Zero deadline switches back to the fallback chain.
+func (r *ProviderRegistry) SetDeadline(deadline time.Duration) {
+	r.deadline = deadline
+}
+
+// Register adds a provider to the registry. Providers with a lower priority value are asked first.
+func (r *ProviderRegistry) Register(name string, priority int, parser ShowtimeParser) {
+	r.providers = append(r.providers, provider{name, priority, parser})
+	sort.SliceStable(r.providers, func(i, j int) bool {
+		return r.providers[i].priority < r.providers[j].priority
+	})
+}
+
+// Names returns registered provider names in the order they are asked
+func (r *ProviderRegistry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for _, p := range r.providers {
+		names = append(names, p.name)
+	}
+	return names
+}
+
+// GetShowtimes asks registered providers for showtimes
+func (r *ProviderRegistry) GetShowtimes(movieName, city, region string, date time.Time) (*SearchResult, error) {
+	return r.GetShowtimesContext(context.Background(), movieName, city, region, date)
+}
+
+// GetShowtimesContext asks registered providers for showtimes until the context is done
+func (r *ProviderRegistry) GetShowtimesContext(ctx context.Context, movieName, city, region string, date time.Time) (*SearchResult, error) {
+	if r.deadline > 0 && len(r.providers) > 1 {
+		return r.fanOut(ctx, movieName, city, region, date)
+	}
+	return r.fallback(ctx, movieName, city, region, date)
+}
+
+// ask waits for the provider until the context is done, a late answer is dropped
+func (p provider) ask(ctx context.Context, movieName, city, region string, date time.Time) (*SearchResult, error) {
+	// buffered, so a late provider doesn't leak the goroutine
+	answer := make(chan providerAnswer, 1)
+	go func() {
+		result, err := p.parser.GetShowtimes(movieName, city, region, date)
+		answer <- providerAnswer{result: result, err: err}
+	}()
+	select {
+	case answer := <-answer:
+		return answer.result, answer.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fallback asks providers in priority order and falls back to the next one
+// when a provider fails or finds nothing
+func (r *ProviderRegistry) fallback(ctx context.Context, movieName, city, region string, date time.Time) (*SearchResult, error) {
+	var lastErr error
+	var emptyResult *SearchResult
+
+	for _, p := range r.providers {
+		if ctx.Err() != nil {
+			log.Printf("[WARN] No time left to ask provider %s for %s", p.name, movieName)
+			break
+		}
+		result, err := p.ask(ctx, movieName, city, region, date)
+		if ambiguous, ok := err.(AmbiguousMovieError); ok {
+			return nil, ambiguous.from(p.name)
+		}
+		if err != nil {
+			if _, ok := err.(NoSuchMovieError); !ok {
+				log.Printf("[WARN] Provider %s failed: %v", p.name, err)
+				lastErr = err
+			}
+			continue
+		}
+		if isNoShowtimes(result) {
+			log.Printf("[INFO] Provider %s found no showtimes for %s", p.name, movieName)
+			if emptyResult == nil {
+				emptyResult = result
+			}
+			continue
+		}
+		return result, nil
+	}
+
+	// nobody has showtimes, but the movie itself exists
+	if emptyResult != nil {
+		return emptyResult, nil
+	}
+	// the failed provider might know the movie, so it is not reported as unknown
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, NoSuchMovie
+}
+
+type providerAnswer struct {
+	index  int
+	result *SearchResult
+	err    error
+}
+
+// fanOut asks all providers concurrently and merges everything that came before the deadline
+// or before the context is done
+func (r *ProviderRegistry) fanOut(ctx context.Context, movieName, city, region string, date time.Time) (*SearchResult, error) {
+	// buffered, so late providers don't leak goroutines after the deadline
+	answers := make(chan providerAnswer, len(r.providers))
+	for i, p := range r.providers {
+		go func(index int, parser ShowtimeParser) {
+			result, err := parser.GetShowtimes(movieName, city, region, date)
+			answers <- providerAnswer{index, result, err}
+		}(i, p.parser)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.deadline)
+	defer cancel()
+
+	// keep results in priority order, so merge prefers names from the first provider
+	results := make([]*SearchResult, len(r.providers))
+	ambiguities := make([]error, len(r.providers))
+	var lastErr error
+
+	for received := 0; received < len(r.providers); received++ {
+		select {
+		case answer := <-answers:
+			name := r.providers[answer.index].name
+			if ambiguous, ok := answer.err.(AmbiguousMovieError); ok {
+				ambiguities[answer.index] = ambiguous.from(name)
+				continue
+			}
+			if answer.err != nil {
+				if _, ok := answer.err.(NoSuchMovieError); !ok {
+					log.Printf("[WARN] Provider %s failed: %v", name, answer.err)
+					lastErr = answer.err
+				}
+				continue
+			}
+			results[answer.index] = answer.result
+		case <-ctx.Done():
+			log.Printf("[WARN] Showtime providers deadline exceeded for %s: %v", movieName, ctx.Err())
+			received = len(r.providers)
+		}
+	}
+
+	// merging showtimes of different movies makes no sense, so the user has to choose first
+	for _, err := range ambiguities {
+		if err != nil {
+			return nil, err
+		}
+	}
+	if merged := MergeResults(r.sameMovie(results)); merged != nil {
+		return merged, nil
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, NoSuchMovie
+}
+
+// sameMovie drops results of other movies than the one found by the first provider,
+// providers may find different movies by the same title
+func (r *ProviderRegistry) sameMovie(results []*SearchResult) []*SearchResult {
+	var first *SearchResult
+	same := make([]*SearchResult, len(results))
+	for i, result := range results {
+		if result == nil {
+			continue
+		}
+		if first == nil {
+			first = result
+		}
+		title, firstTitle := strings.Join(cleanTitle(result.Movie), " "), strings.Join(cleanTitle(first.Movie), " ")
+		if title != firstTitle || result.Year != 0 && first.Year != 0 && result.Year != first.Year {
+			log.Printf("[WARN] Provider %s found %s (%d) instead of %s (%d)", r.providers[i].name, result.Movie, result.Year, first.Movie, first.Year)
+			continue
+		}
+		same[i] = result
+	}
+	return same
+}
+
+// GetMovies collects movies from all providers that can list them
+func (r *ProviderRegistry) GetMovies(city string) ([]Movie, error) {
+	movies := make([]Movie, 0)
+	seen := make(map[string]int)
+	var lastErr error
+
+	for _, p := range r.providers {
+		lister, ok := p.parser.(MovieLister)
+		if !ok {
+			continue
+		}
+		providerMovies, err := lister.GetMovies(city)
+		if err != nil {
+			log.Printf("[WARN] Provider %s failed to list movies: %v", p.name, err)
+			lastErr = err
+			continue
+		}
+		for _, movie := range providerMovies {
+			key := strings.Join(cleanTitle(movie.Title), " ")
+			if i, ok := seen[key]; ok {
+				// providers may know a part of showtimes only
+				if movie.Showtimes > movies[i].Showtimes {
+					movies[i].Showtimes = movie.Showtimes
+				}
+				continue
+			}
+			seen[key] = len(movies)
+			movies = append(movies, movie)
+		}
+	}
+
+	if len(movies) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return movies, nil
+}
+
+// GetMovieShowtimes loads showtimes of the movie from the provider which found it.
+// If the provider is unknown the movie is searched by its title.
+func (r *ProviderRegistry) GetMovieShowtimes(movie Movie, city, region string, date time.Time) (*SearchResult, error) {
+	for _, p := range r.providers {
+		if p.name != movie.Provider {
+			continue
+		}
+		if exact, ok := p.parser.(ExactShowtimeParser); ok {
+			return exact.GetMovieShowtimes(movie, city, region, date)
+		}
+	}
+	return r.GetShowtimes(movie.Title, city, region, date)
+}
+
+// GetCinemas collects cinemas from all providers that can list them, same cinemas are merged
+func (r *ProviderRegistry) GetCinemas(city string) ([]Cinema, error) {
+	cinemas := make([]Cinema, 0)
+	var lastErr error
+
+	for _, p := range r.providers {
+		cinemaParser, ok := p.parser.(CinemaParser)
+		if !ok {
+			continue
+		}
+		providerCinemas, err := cinemaParser.GetCinemas(city)
+		if err != nil {
+			log.Printf("[WARN] Provider %s failed to list cinemas: %v", p.name, err)
+			lastErr = err
+			continue
+		}
+		for _, cinema := range providerCinemas {
+			cinema.Provider = p.name
+			cinemas = mergeCinema(cinemas, cinema)
+		}
+	}
+
+	if len(cinemas) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return cinemas, nil
+}
+
+// GetCinemaShowtimes loads the schedule of the cinema from the provider which found it
+func (r *ProviderRegistry) GetCinemaShowtimes(cinema Cinema, city string, date time.Time) (*CinemaSchedule, error) {
+	for _, p := range r.providers {
+		if p.name != cinema.Provider {
+			continue
+		}
+		if cinemaParser, ok := p.parser.(CinemaParser); ok {
+			return cinemaParser.GetCinemaShowtimes(cinema, city, date)
+		}
+	}
+	return nil, NoSuchCinema
+}
+
+// from marks ambiguous movies with the provider name, so they can be loaded from it later
+func (err AmbiguousMovieError) from(provider string) AmbiguousMovieError {
+	movies := make([]Movie, 0, len(err.Movies))
+	for _, movie := range err.Movies {
+		movie.Provider = provider
+		movies = append(movies, movie)
+	}
+	return AmbiguousMovieError{movies}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type stubParser struct {
+	result *SearchResult
+	err    error
+}
+
+func (s *stubParser) GetShowtimes(movieName, city, region string, date time.Time) (*SearchResult, error) {
+	return s.result, s.err
+}
+
+func TestRegistryFallback(t *testing.T) {
+	outage := errors.New("boom")
+	found := &SearchResult{
+		Movie:   "Пассажир",
+		Cinemas: []Cinema{{Name: "Октябрь", Showtimes: []Showtime{{Time: time.Now()}}}},
+	}
+	var td = []struct {
+		Name      string
+		Providers []*stubParser
+		Err       error
+		Movie     string
+	}{
+		{"first succeeds", []*stubParser{{result: found}, {err: errors.New("boom")}}, nil, "Пассажир"},
+		{"error falls back", []*stubParser{{err: errors.New("layout changed")}, {result: found}}, nil, "Пассажир"},
+		{"empty falls back", []*stubParser{{result: &SearchResult{Movie: "Пустой"}}, {result: found}}, nil, "Пассажир"},
+		{"empty is kept", []*stubParser{{result: &SearchResult{Movie: "Пустой"}}, {err: NoSuchMovie}}, nil, "Пустой"},
+		{"not found", []*stubParser{{err: NoSuchMovie}, {err: NoSuchMovie}}, NoSuchMovie, ""},
+		{"outage is not hidden", []*stubParser{{err: NoSuchMovie}, {err: outage}}, outage, ""},
+	}
+
+	for _, tr := range td {
+		registry := NewProviderRegistry()
+		// register in reverse to check that the priority is respected
+		for i := len(tr.Providers) - 1; i >= 0; i-- {
+			registry.Register(tr.Name, i, tr.Providers[i])
+		}
+
+		result, err := registry.GetShowtimes("пассажир", "Москва", "", time.Now())
+		if err != tr.Err {
+			t.Fatalf("%s: unexpected error %v", tr.Name, err)
+		}
+		if err == nil && result.Movie != tr.Movie {
+			t.Fatalf("%s: wrong movie %s", tr.Name, result.Movie)
+		}
+	}
+}
+
+func TestRegistryConfig(t *testing.T) {
+	registry, err := newProviderRegistry("kinopoisk, rambler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := registry.Names()
+	if len(names) != 2 || names[0] != "kinopoisk" || names[1] != "rambler" {
+		t.Fatalf("wrong providers order: %v", names)
+	}
+	if _, err := newProviderRegistry("rambler,afisha"); err == nil {
+		t.Fatal("unknown provider should fail")
+	}
+}
+
+type slowParser struct {
+	stubParser
+	delay time.Duration
+}
+
+func (s *slowParser) GetShowtimes(movieName, city, region string, date time.Time) (*SearchResult, error) {
+	time.Sleep(s.delay)
+	return s.result, s.err
+}
+
+func TestRegistryFanOut(t *testing.T) {
+	now := time.Now()
+	registry := NewProviderRegistry()
+	registry.SetDeadline(50 * time.Millisecond)
+	registry.Register("rambler", 0, &stubParser{result: &SearchResult{Movie: "Пассажир", Year: 2018, Cinemas: []Cinema{
+		{Name: "Октябрь", Showtimes: []Showtime{{Time: now}}},
+	}}})
+	registry.Register("broken", 1, &stubParser{err: errors.New("boom")})
+	registry.Register("kinopoisk", 2, &stubParser{result: &SearchResult{Movie: "Пассажир", Cinemas: []Cinema{
+		{Name: "Пионер", Showtimes: []Showtime{{Time: now}}},
+	}}})
+	registry.Register("slow", 3, &slowParser{stubParser{result: &SearchResult{Movie: "Пассажир", Cinemas: []Cinema{
+		{Name: "Ролан", Showtimes: []Showtime{{Time: now}}},
+	}}}, time.Second})
+	// other movies with the same title are not merged
+	registry.Register("remake", 4, &stubParser{result: &SearchResult{Movie: "Пассажир", Year: 2008, Cinemas: []Cinema{
+		{Name: "Звезда", Showtimes: []Showtime{{Time: now}}},
+	}}})
+	registry.Register("sequel", 5, &stubParser{result: &SearchResult{Movie: "Пассажиры", Cinemas: []Cinema{
+		{Name: "Салют", Showtimes: []Showtime{{Time: now}}},
+	}}})
+
+	result, err := registry.GetShowtimes("пассажир", "Москва", "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Cinemas) != 2 || result.Year != 2018 {
+		t.Fatalf("expected cinemas of the same movie from two fast providers, got %+v", result.Cinemas)
+	}
+}
+
+func TestRegistryContext(t *testing.T) {
+	registry := NewProviderRegistry()
+	registry.Register("slow", 0, &slowParser{stubParser{err: NoSuchMovie}, time.Second})
+	registry.Register("fast", 1, &stubParser{result: &SearchResult{Movie: "Пассажир"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	if _, err := registry.GetShowtimesContext(ctx, "пассажир", "Москва", "", time.Now()); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if time.Since(started) > 500*time.Millisecond {
+		t.Fatal("the registry should not wait for the provider after the context is done")
+	}
+}
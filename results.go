@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// how many cinemas are spoken in one answer
+const cinemasPerPage = 3
+
+// how many showtimes are spoken for every cinema
+const showtimesPerCinema = 2
+
+// how many movies of a cinema are spoken in one answer
+const moviesPerCinema = 5
+
+// how many movies are spoken when the user asks what is showing
+const repertoireSize = 5
+
+// Query is a movie or a cinema and showtime constraints the user asked for
+type Query struct {
+	Movie    Movie    `json:"movie"`
+	Cinema   *Cinema  `json:"cinema,omitempty"`
+	Entities Entities `json:"entities"`
+}
+
+// Results are the last found showtimes kept in the session to page through them without a new search
+type Results struct {
+	Movie     string    `json:"movie"`
+	Date      time.Time `json:"date"`
+	Cinemas   []Cinema  `json:"cinemas"`
+	Offset    int       `json:"offset"`
+	LastShown time.Time `json:"lastShown"`
+	// cinemas are sorted by price and prices are spoken
+	ByPrice bool   `json:"byPrice,omitempty"`
+	Poster  string `json:"poster,omitempty"`
+}
+
+// NewResults creates results with ranked cinemas, nothing is shown yet
+func NewResults(movie string, date time.Time, cinemas []Cinema) *Results {
+	return &Results{Movie: movie, Date: date, Cinemas: cinemas}
+}
+
+// HasMore checks if there are cinemas which were not shown yet
+func (r *Results) HasMore() bool {
+	return r.Offset < len(r.Cinemas)
+}
+
+// NextPage returns cinemas which were not shown yet and remembers them as shown
+func (r *Results) NextPage() []Cinema {
+	end := r.Offset + cinemasPerPage
+	if end > len(r.Cinemas) {
+		end = len(r.Cinemas)
+	}
+	page := r.Cinemas[r.Offset:end]
+	r.Offset = end
+
+	for _, cinema := range page {
+		for i, showtime := range cinema.Showtimes {
+			if i >= showtimesPerCinema {
+				break
+			}
+			if showtime.Time.After(r.LastShown) {
+				r.LastShown = showtime.Time
+			}
+		}
+	}
+	return page
+}
+
+// Later keeps only showtimes after the shown ones and starts paging from the first cinema
+func (r *Results) Later() bool {
+	later := filterShowtimes(&SearchResult{Cinemas: r.Cinemas}, func(showtime Showtime) bool {
+		return showtime.Time.After(r.LastShown)
+	})
+	if len(later) == 0 {
+		return false
+	}
+	r.Cinemas = later
+	r.Offset = 0
+	return true
+}
+
+// describeCinemas speaks the first showtimes of every cinema, the first cinema is spoken in a full sentence
+func describeCinemas(cinemas []Cinema, withPrices bool, phrases Phrases) string {
+	describeShowtime := func(showtime Showtime) string {
+		description := describeShowtime(showtime)
+		if price := spokenPrice(showtime, phrases); withPrices && price != "" {
+			description += " " + price
+		}
+		return description
+	}
+
+	sentences := make([]string, 0, len(cinemas))
+	for i, cinema := range cinemas {
+		data := AnswerData{Cinema: cinema.Name, Time: describeShowtime(cinema.Showtimes[0])}
+		tag := "CINEMA_SHOWTIME"
+		if len(cinema.Showtimes) > 1 {
+			data.NextTime = describeShowtime(cinema.Showtimes[1])
+			tag = "CINEMA_SHOWTIMES"
+		}
+		if i == 0 {
+			tag = "FIRST_" + tag
+		}
+		sentences = append(sentences, phrases(tag, data))
+	}
+	return strings.Join(sentences, " ")
+}
+
+// describeSchedule speaks the nearest showtimes of movies in a cinema
+func describeSchedule(movies []MovieShowtimes, phrases Phrases) string {
+	if len(movies) > moviesPerCinema {
+		movies = movies[:moviesPerCinema]
+	}
+	descriptions := make([]string, 0, len(movies))
+	for _, movie := range movies {
+		data := AnswerData{Movie: movie.Movie, Time: describeShowtime(movie.Showtimes[0])}
+		tag := "MOVIE_SHOWTIME"
+		if len(movie.Showtimes) > 1 {
+			data.NextTime = describeShowtime(movie.Showtimes[1])
+			tag = "MOVIE_SHOWTIMES"
+		}
+		descriptions = append(descriptions, phrases(tag, data))
+	}
+	return strings.Join(descriptions, ", ") + "."
+}
+
+// describeShowtime speaks the start of the showtime and its format if it is not a usual one
+func describeShowtime(showtime Showtime) string {
+	description := showtime.Time.Format("15:04")
+	if format := showtime.Tags().Spoken(); format != "" {
+		description += " " + format
+	}
+	return description
+}
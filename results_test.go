@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestResultsPaging(t *testing.T) {
+	results := NewResults("Пассажир", testDay, []Cinema{
+		{Name: "Октябрь", Showtimes: []Showtime{testShowtime(12, 0), testShowtime(14, 0), testShowtime(22, 0)}},
+		{Name: "Пионер", Showtimes: []Showtime{testShowtime(13, 0)}},
+		{Name: "Формула кино", Showtimes: []Showtime{testShowtime(15, 0), testShowtime(19, 0)}},
+		{Name: "Каро", Showtimes: []Showtime{testShowtime(16, 0)}},
+	})
+
+	page := results.NextPage()
+	if len(page) != cinemasPerPage || !results.HasMore() {
+		t.Fatalf("wrong first page %v", page)
+	}
+	if results.LastShown.Format("15:04") != "19:00" {
+		t.Fatalf("wrong last shown time %v", results.LastShown)
+	}
+	page = results.NextPage()
+	if len(page) != 1 || page[0].Name != "Каро" || results.HasMore() {
+		t.Fatalf("wrong second page %v", page)
+	}
+
+	if !results.Later() {
+		t.Fatal("later showtimes are not found")
+	}
+	page = results.NextPage()
+	if len(page) != 1 || page[0].Name != "Октябрь" || page[0].Showtimes[0].Time.Format("15:04") != "22:00" {
+		t.Fatalf("wrong later page %v", page)
+	}
+	if results.Later() {
+		t.Fatal("there are no showtimes after 22:00")
+	}
+}
+
+func TestFollowUpTemplates(t *testing.T) {
+	more, later := More(), Later()
+	for _, phrase := range []string{"еще", "а другие кинотеатры", "дальше", "где еще показывают", "давай"} {
+		if _, ok := more.Matches(phrase); !ok {
+			t.Fatalf("%q is not a request for more cinemas", phrase)
+		}
+	}
+	for _, phrase := range []string{"а позже", "есть что-нибудь попозже", "более поздние сеансы"} {
+		if _, ok := later.Matches(phrase); !ok {
+			t.Fatalf("%q is not a request for later showtimes", phrase)
+		}
+	}
+	for _, phrase := range []string{"еще один фильм", "расписание пассажира"} {
+		if _, ok := more.Matches(phrase); ok {
+			t.Fatalf("%q is matched as a request for more cinemas", phrase)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
@@ -8,14 +10,38 @@ import (
 )
 
 const tableName = "alice-cinema-skill"
+const sessionsTableName = "alice-cinema-skill-sessions"
+
+// sessions are removed by DynamoDB TTL after this time
+const sessionTTL = 24 * time.Hour
 
 // Location contains information about users location
 type Location struct {
-	UserID     string `json:"userID"`
-	InProgress bool   `json:"inProgress"`
-	Completed  bool   `json:"completed"`
-	Subway     string `json:"subway"`
-	City       string `json:"city"`
+	UserID string      `json:"userID"`
+	State  DialogState `json:"state"`
+	Subway string      `json:"subway"`
+	City   string      `json:"city"`
+	// region tells apart towns with the same name
+	Region string `json:"region,omitempty"`
+	// position of the station or the street the user told about
+	Position *Point `json:"position,omitempty"`
+}
+
+// SessionState contains information about the current dialog with the user
+type SessionState struct {
+	SessionID string `json:"sessionID"`
+	ExpiresAt int64  `json:"expiresAt"`
+	// movies the user has to choose from and the search they were found for
+	PendingChoices  []Movie  `json:"pendingChoices,omitempty"`
+	PendingEntities Entities `json:"pendingEntities"`
+	// cinema the user was asked about when its name was not recognized well
+	PendingCinema *Cinema `json:"pendingCinema,omitempty"`
+	// places the user has to choose from when the address was ambiguous
+	PendingLocations []Location `json:"pendingLocations,omitempty"`
+	// the last search of the user, follow-up questions change it
+	Query *Query `json:"query,omitempty"`
+	// the last showtimes found for the user
+	Results *Results `json:"results,omitempty"`
 }
 
 // LocationStorage provides a storage for user location
@@ -24,13 +50,26 @@ type LocationStorage interface {
 	Save(userID string, location *Location) error
 }
 
+// SessionStorage provides a storage for the state of dialog sessions
+type SessionStorage interface {
+	GetSession(sessionID string) (*SessionState, error)
+	SaveSession(sessionID string, state *SessionState) error
+}
+
+// Storage provides both user locations and dialog sessions
+type Storage interface {
+	LocationStorage
+	SessionStorage
+}
+
 // InMemoryStorage stores info in a map
 type InMemoryStorage struct {
-	store map[string]*Location
+	store    map[string]*Location
+	sessions map[string]*SessionState
 }
 
 func NewStorage() *InMemoryStorage {
-	return &InMemoryStorage{make(map[string]*Location)}
+	return &InMemoryStorage{make(map[string]*Location), make(map[string]*SessionState)}
 }
 
 func (s *InMemoryStorage) Get(userID string) (*Location, error) {
@@ -45,6 +84,18 @@ func (s *InMemoryStorage) Save(userID string, location *Location) error {
 	return nil
 }
 
+func (s *InMemoryStorage) GetSession(sessionID string) (*SessionState, error) {
+	if state, ok := s.sessions[sessionID]; ok {
+		return state, nil
+	}
+	return &SessionState{}, nil
+}
+
+func (s *InMemoryStorage) SaveSession(sessionID string, state *SessionState) error {
+	s.sessions[sessionID] = state
+	return nil
+}
+
 // DynamoStorage stores info in AWS DynamoDB
 type DynamoStorage struct {
 	client *dynamodb.DynamoDB
@@ -108,3 +159,46 @@ func (d *DynamoStorage) Save(userID string, location *Location) error {
 
 	return nil
 }
+
+func (d *DynamoStorage) GetSession(sessionID string) (*SessionState, error) {
+	result, err := d.client.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(sessionsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"sessionID": {
+				S: aws.String(sessionID),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var state SessionState
+	err = dynamodbattribute.UnmarshalMap(result.Item, &state)
+	if err != nil {
+		return nil, err
+	}
+
+	// New session
+	if state.SessionID == "" {
+		return &SessionState{}, nil
+	}
+
+	return &state, nil
+}
+
+func (d *DynamoStorage) SaveSession(sessionID string, state *SessionState) error {
+	state.SessionID = sessionID
+	state.ExpiresAt = time.Now().Add(sessionTTL).Unix()
+
+	av, err := dynamodbattribute.MarshalMap(state)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.client.PutItem(
+		&dynamodb.PutItemInput{
+			TableName: aws.String(sessionsTableName),
+			Item:      av,
+		})
+	return err
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"container/heap"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// subwayVersion is the format of subway maps: stations by lines with transfers
+const subwayVersion = 1
+
+// defaultSubways are subway maps of cities built into the skill
+//
+//go:embed subway.json
+var defaultSubways []byte
+
+type subwayFile struct {
+	Version int                   `json:"version"`
+	Cities  map[string]subwayCity `json:"cities"`
+}
+
+// subwayCity describes lines of a city, hop and transfer are minutes between neighbour stations and between lines
+type subwayCity struct {
+	Hop       int          `json:"hop"`
+	Transfer  int          `json:"transfer"`
+	Lines     []subwayLine `json:"lines"`
+	Transfers [][]string   `json:"transfers"`
+}
+
+type subwayLine struct {
+	Name     string   `json:"name"`
+	Hop      int      `json:"hop"`
+	Circle   bool     `json:"circle"`
+	Stations []string `json:"stations"`
+}
+
+type subwayEdge struct {
+	to      int
+	minutes int
+}
+
+// SubwayMap is a graph of subway stations of a city. A station on several lines is a node on every line,
+// the nodes are connected by transfers.
+type SubwayMap struct {
+	stations []string
+	nodes    map[string][]int
+	edges    [][]subwayEdge
+}
+
+// Subways keeps subway maps of cities with metro
+type Subways map[string]*SubwayMap
+
+// DefaultSubways returns subway maps built into the skill
+func DefaultSubways() Subways {
+	subways, err := LoadSubways(defaultSubways)
+	if err != nil {
+		panic("built-in subway maps are broken: " + err.Error())
+	}
+	return subways
+}
+
+// LoadSubways builds subway maps from a versioned json description
+func LoadSubways(data []byte) (Subways, error) {
+	var file subwayFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Version != subwayVersion {
+		return nil, fmt.Errorf("unsupported subway version %d", file.Version)
+	}
+	subways := make(Subways, len(file.Cities))
+	for city, description := range file.Cities {
+		subway, err := newSubwayMap(description)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", city, err)
+		}
+		subways[normalizeStation(city)] = subway
+	}
+	return subways, nil
+}
+
+func newSubwayMap(city subwayCity) (*SubwayMap, error) {
+	m := &SubwayMap{nodes: make(map[string][]int)}
+	for _, line := range city.Lines {
+		hop := line.Hop
+		if hop == 0 {
+			hop = city.Hop
+		}
+		if hop <= 0 || len(line.Stations) < 2 {
+			return nil, fmt.Errorf("line %s has no stations or travel time", line.Name)
+		}
+		first := len(m.stations)
+		for i, station := range line.Stations {
+			node := m.addStation(station)
+			if i > 0 {
+				m.connect(node-1, node, hop)
+			}
+		}
+		if line.Circle {
+			m.connect(first, len(m.stations)-1, hop)
+		}
+	}
+
+	// stations with the same name on different lines are connected by transfers
+	for _, nodes := range m.nodes {
+		m.connectAll(nodes, city.Transfer)
+	}
+	for _, transfer := range city.Transfers {
+		nodes := make([]int, 0, len(transfer))
+		for _, station := range transfer {
+			found, ok := m.nodes[normalizeStation(station)]
+			if !ok {
+				return nil, fmt.Errorf("unknown transfer station %s", station)
+			}
+			nodes = append(nodes, found...)
+		}
+		m.connectAll(nodes, city.Transfer)
+	}
+	return m, nil
+}
+
+func (m *SubwayMap) addStation(name string) int {
+	node := len(m.stations)
+	m.stations = append(m.stations, normalizeStation(name))
+	m.edges = append(m.edges, nil)
+	m.nodes[m.stations[node]] = append(m.nodes[m.stations[node]], node)
+	return node
+}
+
+func (m *SubwayMap) connect(a, b, minutes int) {
+	m.edges[a] = append(m.edges[a], subwayEdge{b, minutes})
+	m.edges[b] = append(m.edges[b], subwayEdge{a, minutes})
+}
+
+func (m *SubwayMap) connectAll(nodes []int, minutes int) {
+	for i := range nodes {
+		for j := i + 1; j < len(nodes); j++ {
+			m.connect(nodes[i], nodes[j], minutes)
+		}
+	}
+}
+
+// TravelTimes returns minutes of riding from the station to every station of the map,
+// false if there is no such station
+func (m *SubwayMap) TravelTimes(station string) (map[string]int, bool) {
+	start, ok := m.nodes[normalizeStation(station)]
+	if !ok {
+		return nil, false
+	}
+	distances := make([]int, len(m.stations))
+	for i := range distances {
+		distances[i] = -1
+	}
+	queue := &subwayQueue{}
+	for _, node := range start {
+		distances[node] = 0
+		heap.Push(queue, subwayEdge{node, 0})
+	}
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(subwayEdge)
+		if current.minutes > distances[current.to] {
+			continue
+		}
+		for _, edge := range m.edges[current.to] {
+			minutes := current.minutes + edge.minutes
+			if distances[edge.to] == -1 || minutes < distances[edge.to] {
+				distances[edge.to] = minutes
+				heap.Push(queue, subwayEdge{edge.to, minutes})
+			}
+		}
+	}
+
+	times := make(map[string]int, len(m.nodes))
+	for node, minutes := range distances {
+		if known, ok := times[m.stations[node]]; minutes >= 0 && (!ok || minutes < known) {
+			times[m.stations[node]] = minutes
+		}
+	}
+	return times, true
+}
+
+// subwayQueue is a priority queue of nodes by minutes of riding to them
+type subwayQueue []subwayEdge
+
+func (q subwayQueue) Len() int            { return len(q) }
+func (q subwayQueue) Less(i, j int) bool  { return q[i].minutes < q[j].minutes }
+func (q subwayQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *subwayQueue) Push(x interface{}) { *q = append(*q, x.(subwayEdge)) }
+func (q *subwayQueue) Pop() interface{} {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
+
+// normalizeStation makes a comparable station name: "м. Парк Победы" -> "парк победы"
+func normalizeStation(name string) string {
+	words := splitWords(name)
+	if len(words) > 0 && (words[0] == "м" || words[0] == "метро" || words[0] == "ст") {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// cinemaStations splits subway text of a cinema into station names: "м. Арбатская, м. Смоленская"
+func cinemaStations(subway string) []string {
+	stations := make([]string, 0)
+	for _, part := range strings.FieldsFunc(subway, func(r rune) bool { return r == ',' || r == ';' || r == '/' }) {
+		if station := normalizeStation(part); station != "" {
+			stations = append(stations, station)
+		}
+	}
+	return stations
+}
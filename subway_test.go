@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestSubwayTravelTimes(t *testing.T) {
+	moscow := DefaultSubways()["москва"]
+	if moscow == nil {
+		t.Fatal("no subway map of Moscow")
+	}
+	times, ok := moscow.TravelTimes("м. Сокол")
+	if !ok {
+		t.Fatal("Сокол should be on the map")
+	}
+	var td = []struct {
+		Station string
+		Minutes int
+	}{
+		{"сокол", 0},
+		{"тверская", 10},
+		// transfer to the same station of another line
+		{"белорусская", 6},
+		// transfer between stations with different names
+		{"пушкинская", 10 + 4},
+		{"чеховская", 10 + 4},
+		{"лубянка", 12 + 4 + 2},
+	}
+	for _, tr := range td {
+		if minutes := times[tr.Station]; minutes != tr.Minutes {
+			t.Errorf("wrong travel time to %s: %d", tr.Station, minutes)
+		}
+	}
+
+	// the circle line is closed
+	times, _ = moscow.TravelTimes("Парк культуры")
+	if minutes := times["киевская"]; minutes != 3 {
+		t.Errorf("wrong travel time on the circle line: %d", minutes)
+	}
+	if _, ok := moscow.TravelTimes("Хогвартс"); ok {
+		t.Error("unknown station should not be found")
+	}
+}
+
+func TestLoadSubways(t *testing.T) {
+	var td = []string{
+		`{"version": 2, "cities": {}}`,
+		`{"version": 1, "cities": {"Казань": {"hop": 2, "lines": [{"name": "Центральная", "stations": ["Кремлевская"]}]}}}`,
+		`{"version": 1, "cities": {"Казань": {"lines": [{"name": "Центральная", "stations": ["Кремлевская", "Площадь Тукая"]}]}}}`,
+		`{"version": 1, "cities": {"Казань": {"hop": 2, "lines": [{"name": "Центральная", "stations": ["Кремлевская", "Площадь Тукая"]}], "transfers": [["Кремлевская", "Суконная слобода"]]}}}`,
+	}
+	for _, data := range td {
+		if _, err := LoadSubways([]byte(data)); err == nil {
+			t.Errorf("broken subway should not be loaded: %s", data)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+// a screen shows only a few buttons, the rest are hidden behind scrolling
+const maxTicketButtons = 6
+
+// ticketButtons creates buttons to buy tickets for the showtimes spoken of every cinema
+func ticketButtons(cinemas []Cinema, phrases Phrases) []Button {
+	buttons := make([]Button, 0)
+	for _, cinema := range cinemas {
+		for i, showtime := range cinema.Showtimes {
+			if i >= showtimesPerCinema {
+				break
+			}
+			title := phrases("CINEMA_TICKET", AnswerData{Cinema: cinema.Name, Time: showtime.Time.Format("15:04")})
+			buttons = appendTicket(buttons, title, showtime)
+		}
+	}
+	return buttons
+}
+
+// scheduleTicketButtons creates buttons to buy tickets for the showtimes spoken of every movie in a cinema
+func scheduleTicketButtons(movies []MovieShowtimes, phrases Phrases) []Button {
+	buttons := make([]Button, 0)
+	for i, movie := range movies {
+		if i >= moviesPerCinema {
+			break
+		}
+		for j, showtime := range movie.Showtimes {
+			if j >= showtimesPerCinema {
+				break
+			}
+			title := phrases("MOVIE_TICKET", AnswerData{Movie: movie.Movie, Time: showtime.Time.Format("15:04")})
+			buttons = appendTicket(buttons, title, showtime)
+		}
+	}
+	return buttons
+}
+
+// screeningTicketButtons creates buttons to buy tickets for the soonest showtimes
+func screeningTicketButtons(screenings []Screening, phrases Phrases) []Button {
+	buttons := make([]Button, 0)
+	for _, screening := range screenings {
+		title := phrases("SCREENING_TICKET", AnswerData{Movie: screening.Movie, Cinema: screening.Cinema})
+		buttons = appendTicket(buttons, title, screening.Showtime)
+	}
+	return buttons
+}
+
+func appendTicket(buttons []Button, title string, showtime Showtime) []Button {
+	if showtime.TicketURL == "" || len(buttons) == maxTicketButtons {
+		return buttons
+	}
+	return append(buttons, Button{Title: title, URL: showtime.TicketURL})
+}
+
+// withTickets puts ticket buttons before other buttons of the response
+func withTickets(response *AliceResponse, tickets []Button) *AliceResponse {
+	response.Response.Buttons = append(tickets, response.Response.Buttons...)
+	return response
+}
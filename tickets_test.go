@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestTicketButtons(t *testing.T) {
+	cinemas := []Cinema{
+		{Name: "Октябрь", Showtimes: []Showtime{testTicket(18, "https://tickets/1"), testTicket(20, ""), testTicket(22, "https://tickets/3")}},
+		{Name: "Пионер", Showtimes: []Showtime{testTicket(19, "https://tickets/4")}},
+	}
+
+	buttons := ticketButtons(cinemas, testPhrases)
+	if len(buttons) != 2 {
+		t.Fatalf("only spoken showtimes with links should have buttons: %v", buttons)
+	}
+	if buttons[0].Title != "Купить билет в Октябрь на 18:00" || buttons[0].URL != "https://tickets/1" || buttons[0].Hide {
+		t.Fatalf("wrong ticket button: %+v", buttons[0])
+	}
+
+	response := withTickets(sayWithButtons(Session{}, "test"), buttons)
+	if len(response.Response.Buttons) != 4 || response.Response.Buttons[0].URL == "" || response.Response.Buttons[3].URL != "" {
+		t.Fatalf("ticket buttons should go first: %+v", response.Response.Buttons)
+	}
+}
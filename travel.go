@@ -0,0 +1,177 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// time to get from home into the subway and from a station to the cinema
+const travelOverhead = 15 * time.Minute
+
+// cinemas farther than this are not offered while there are closer ones
+const maxTravelTime = time.Hour
+
+// cinemas farther than this in kilometers are not offered while there are closer ones
+const searchRadius = 15.0
+
+// average speed in kilometers per hour of getting around the city without a subway map
+const travelSpeed = 20.0
+
+// Travel estimates how long it takes the user to get to cinemas by subway or,
+// without a subway, by the distance to them
+type Travel struct {
+	station string
+	// minutes of riding to every station, nil if the city or the station is not on a subway map
+	minutes  map[string]int
+	position *Point
+}
+
+// Travel prepares estimates from the station or the position of the user, nil if both are unknown
+func (s Subways) Travel(location *Location) *Travel {
+	station := normalizeStation(location.Subway)
+	if station == "" && location.Position == nil {
+		return nil
+	}
+	travel := &Travel{station: station, position: location.Position}
+	if subway, ok := s[normalizeStation(location.City)]; ok && station != "" {
+		travel.minutes, _ = subway.TravelTimes(station)
+	}
+	return travel
+}
+
+// To estimates the time to get to the cinema through the closest of its stations,
+// cinemas not on the subway map are estimated by the distance
+func (t *Travel) To(cinema Cinema) (time.Duration, bool) {
+	if t == nil {
+		return 0, false
+	}
+	if travel, ok := t.bySubway(cinema); ok {
+		return travel, true
+	}
+	distance, ok := t.distance(cinema)
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(distance/travelSpeed*float64(time.Hour)) + travelOverhead, true
+}
+
+// distance returns kilometers to the cinema if positions of both the user and the cinema are known
+func (t *Travel) distance(cinema Cinema) (float64, bool) {
+	if t.position == nil || cinema.Position == nil {
+		return 0, false
+	}
+	return t.position.DistanceTo(*cinema.Position), true
+}
+
+func (t *Travel) bySubway(cinema Cinema) (time.Duration, bool) {
+	if t.minutes == nil {
+		return 0, false
+	}
+	best, found := 0, false
+	for _, station := range cinemaStations(cinema.Subway) {
+		if minutes, ok := t.minutes[station]; ok && (!found || minutes < best) {
+			best, found = minutes, true
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	return time.Duration(best)*time.Minute + travelOverhead, true
+}
+
+// near checks if the cinema is at the station of the user
+func (t *Travel) near(cinema Cinema) bool {
+	if t.station == "" {
+		return false
+	}
+	for _, station := range cinemaStations(cinema.Subway) {
+		if station == t.station {
+			return true
+		}
+	}
+	return false
+}
+
+// Rank orders cinemas by travel time, cinemas with unknown stations and positions go last.
+// Too far cinemas are dropped while there are closer ones. Without a subway map and positions
+// cinemas at the station of the user go first.
+func (t *Travel) Rank(cinemas []Cinema) []Cinema {
+	if t == nil {
+		return cinemas
+	}
+	type ranked struct {
+		cinema Cinema
+		travel time.Duration
+		known  bool
+		far    bool
+	}
+	rankedCinemas := make([]ranked, 0, len(cinemas))
+	hasClose := false
+	for _, cinema := range cinemas {
+		travel, known := t.To(cinema)
+		if !known && t.near(cinema) {
+			travel, known = travelOverhead, true
+		}
+		far := known && (travel > maxTravelTime || t.outside(cinema))
+		hasClose = hasClose || known && !far
+		rankedCinemas = append(rankedCinemas, ranked{cinema, travel, known, far})
+	}
+	sort.SliceStable(rankedCinemas, func(i, j int) bool {
+		if rankedCinemas[i].known != rankedCinemas[j].known {
+			return rankedCinemas[i].known
+		}
+		return rankedCinemas[i].travel < rankedCinemas[j].travel
+	})
+
+	result := make([]Cinema, 0, len(cinemas))
+	for _, r := range rankedCinemas {
+		if hasClose && r.far {
+			continue
+		}
+		result = append(result, r.cinema)
+	}
+	return result
+}
+
+// outside checks if the cinema is farther than the search radius
+func (t *Travel) outside(cinema Cinema) bool {
+	distance, ok := t.distance(cinema)
+	return ok && distance > searchRadius
+}
+
+// Reachable drops showtimes starting before the user can get to the cinema,
+// cinemas without showtimes left are dropped too
+func (t *Travel) Reachable(cinemas []Cinema, now time.Time) []Cinema {
+	reachable := make([]Cinema, 0, len(cinemas))
+	for _, cinema := range cinemas {
+		travel, _ := t.To(cinema)
+		arrival := now.Add(travel)
+		copyCinema := cinema
+		copyCinema.Showtimes = selectShowtimes(cinema.Showtimes, func(showtime Showtime) bool {
+			return !showtime.Time.Before(arrival)
+		})
+		if len(copyCinema.Showtimes) != 0 {
+			reachable = append(reachable, copyCinema)
+		}
+	}
+	return reachable
+}
+
+// ReachableSchedule drops showtimes of the cinema schedule starting before the user can get there
+func (t *Travel) ReachableSchedule(schedule *CinemaSchedule, now time.Time) *CinemaSchedule {
+	if schedule == nil {
+		return nil
+	}
+	travel, _ := t.To(schedule.Cinema)
+	arrival := now.Add(travel)
+	reachable := &CinemaSchedule{Cinema: schedule.Cinema, Movies: make([]MovieShowtimes, 0, len(schedule.Movies))}
+	for _, movie := range schedule.Movies {
+		showtimes := selectShowtimes(movie.Showtimes, func(showtime Showtime) bool {
+			return !showtime.Time.Before(arrival)
+		})
+		if len(showtimes) != 0 {
+			reachable.Movies = append(reachable.Movies, MovieShowtimes{Movie: movie.Movie, Showtimes: showtimes})
+		}
+	}
+	return reachable
+}
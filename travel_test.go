@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTravelRanking(t *testing.T) {
+	now := time.Date(2018, 3, 15, 18, 0, 0, 0, time.UTC)
+	cinemas := []Cinema{
+		{Name: "Ереван Плаза", Subway: "м. Медведково", Showtimes: []Showtime{testShowtime(19, 0)}},
+		{Name: "Без метро", Showtimes: []Showtime{testShowtime(18, 10)}},
+		{Name: "Пионер", Subway: "м. Кутузовская", Showtimes: []Showtime{testShowtime(18, 20), testShowtime(19, 0)}},
+		{Name: "Октябрь", Subway: "м. Арбатская, м. Смоленская", Showtimes: []Showtime{testShowtime(18, 30), testShowtime(19, 0)}},
+	}
+	travel := DefaultSubways().Travel(&Location{City: "Москва", Subway: "Бульвар Дмитрия Донского"})
+
+	ranked := travel.Rank(cinemas)
+	if len(ranked) != 3 || ranked[0].Name != "Октябрь" || ranked[1].Name != "Пионер" || ranked[2].Name != "Без метро" {
+		t.Fatalf("wrong ranking, far cinemas should be dropped: %v", ranked)
+	}
+
+	reachable := travel.Reachable(ranked, now)
+	if len(reachable) != 3 || len(reachable[0].Showtimes) != 1 || reachable[0].Showtimes[0] != testShowtime(19, 0) {
+		t.Fatalf("unreachable showtimes should be dropped: %v", reachable)
+	}
+	if len(reachable[1].Showtimes) != 1 || len(reachable[2].Showtimes) != 1 {
+		t.Fatalf("wrong reachable showtimes: %v", reachable)
+	}
+
+	schedule := &CinemaSchedule{Cinema: cinemas[2], Movies: []MovieShowtimes{
+		{Movie: "Дюна", Showtimes: []Showtime{testShowtime(18, 20)}},
+		{Movie: "Оно", Showtimes: []Showtime{testShowtime(18, 20), testShowtime(19, 0)}},
+	}}
+	if reachableSchedule := travel.ReachableSchedule(schedule, now); len(reachableSchedule.Movies) != 1 || reachableSchedule.Movies[0].Movie != "Оно" {
+		t.Fatalf("wrong reachable schedule: %v", reachableSchedule)
+	}
+
+	// without a subway map cinemas at the station of the user go first
+	kazan := DefaultSubways().Travel(&Location{City: "Казань", Subway: "Кремлевская"})
+	ranked = kazan.Rank([]Cinema{{Name: "Мир"}, {Name: "Синема 5", Subway: "Кремлёвская"}})
+	if ranked[0].Name != "Синема 5" || len(ranked) != 2 {
+		t.Fatalf("wrong ranking without a subway map: %v", ranked)
+	}
+}
+
+func TestDistanceRanking(t *testing.T) {
+	now := time.Date(2018, 3, 15, 18, 0, 0, 0, time.UTC)
+	cinemas := []Cinema{
+		{Name: "Саяны", Position: &Point{Lat: 53.09, Lon: 91.40}, Showtimes: []Showtime{testShowtime(19, 0)}},
+		{Name: "Без адреса", Showtimes: []Showtime{testShowtime(18, 10)}},
+		{Name: "Пионер", Position: &Point{Lat: 53.72, Lon: 91.52}, Showtimes: []Showtime{testShowtime(18, 20), testShowtime(19, 0)}},
+		{Name: "Родина", Position: &Point{Lat: 53.725, Lon: 91.44}, Showtimes: []Showtime{testShowtime(18, 10), testShowtime(18, 30)}},
+	}
+	travel := DefaultSubways().Travel(&Location{City: "Абакан", Position: &Point{Lat: 53.72, Lon: 91.44}})
+
+	ranked := travel.Rank(cinemas)
+	if len(ranked) != 3 || ranked[0].Name != "Родина" || ranked[1].Name != "Пионер" || ranked[2].Name != "Без адреса" {
+		t.Fatalf("wrong ranking, cinemas outside of the radius should be dropped: %v", ranked)
+	}
+	reachable := travel.Reachable(ranked, now)
+	if len(reachable) != 3 || len(reachable[0].Showtimes) != 1 || reachable[0].Showtimes[0] != testShowtime(18, 30) {
+		t.Fatalf("unreachable showtimes should be dropped: %v", reachable)
+	}
+	if len(reachable[1].Showtimes) != 1 || len(reachable[2].Showtimes) != 1 {
+		t.Fatalf("wrong reachable showtimes: %v", reachable)
+	}
+
+	if DefaultSubways().Travel(&Location{City: "Абакан"}) != nil {
+		t.Fatal("nothing to estimate without a station and a position")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pause between sentences, so cinemas are not read as one long phrase
+const sentencePause = " sil <[300]> "
+
+// pronunciations of names that Alice reads wrong, "+" marks the stressed vowel
+var pronunciations = strings.NewReplacer(
+	"«", "",
+	"»", "",
+	"IMAX", "айм+акс",
+	"4DX", "четыре ди икс",
+	"3D", "три д+э",
+	"2D", "два д+э",
+	"Dolby Atmos", "д+олби +атмос",
+	"КАРО", "к+аро",
+	"Каро", "к+аро",
+	"Синема Парк", "син+ема парк",
+	"Формула Кино", "ф+ормула кин+о",
+	"Мираж Синема", "мир+аж син+ема",
+	"Москино", "москин+о",
+)
+
+// abbreviations in cinema addresses, matched as whole words so "им." is not read as "иметро"
+var abbreviations = map[string]string{
+	"м":  "метро",
+	"им": "имени",
+}
+
+var abbreviationRe = regexp.MustCompile(`(^|[^\p{L}])(м|им)\. `)
+
+var clockRe = regexp.MustCompile(`(\d{1,2}):(\d{2})`)
+
+var sentenceEndRe = regexp.MustCompile(`([.!?]) +`)
+
+// hours as they are said on the clock face
+var spokenHours = []string{"двенадцать", "час", "два", "три", "четыре", "пять", "шесть",
+	"семь", "восемь", "девять", "десять", "одиннадцать", "двенадцать"}
+
+// ordinal hours in genitive for half hours: "в половине восьмого"
+var halfHours = []string{"первого", "второго", "третьего", "четвертого", "пятого", "шестого",
+	"седьмого", "восьмого", "девятого", "десятого", "одиннадцатого", "двенадцатого"}
+
+var units = []string{"ноль", "один", "два", "три", "четыре", "пять", "шесть", "семь", "восемь", "девять",
+	"десять", "одиннадцать", "двенадцать", "тринадцать", "четырнадцать", "пятнадцать",
+	"шестнадцать", "семнадцать", "восемнадцать", "девятнадцать"}
+
+var tens = []string{"", "", "двадцать", "тридцать", "сорок", "пятьдесят"}
+
+// RenderTTS converts an answer text to a text for speech synthesis:
+// times are said in words, names are said with proper stresses and sentences are separated by pauses
+func RenderTTS(text string) string {
+	tts := pronunciations.Replace(text)
+	tts = abbreviationRe.ReplaceAllStringFunc(tts, func(match string) string {
+		parts := abbreviationRe.FindStringSubmatch(match)
+		return parts[1] + abbreviations[parts[2]] + " "
+	})
+	tts = clockRe.ReplaceAllStringFunc(tts, func(clock string) string {
+		parts := clockRe.FindStringSubmatch(clock)
+		hour, _ := strconv.Atoi(parts[1])
+		minute, _ := strconv.Atoi(parts[2])
+		if hour > 23 || minute > 59 {
+			return clock
+		}
+		return spokenTime(hour, minute)
+	})
+	tts = sentenceEndRe.ReplaceAllString(tts, "$1"+sentencePause)
+	return strings.TrimSpace(tts)
+}
+
+// spokenTime says a time like people do: "семь вечера", "половине восьмого", "девятнадцать сорок"
+func spokenTime(hour, minute int) string {
+	switch minute {
+	case 0:
+		return spokenHours[hour%12] + " " + partOfDayName(hour)
+	case 30:
+		return "половине " + halfHours[hour%12]
+	}
+	if minute < 10 {
+		return spokenNumber(hour, false) + " ноль " + spokenNumber(minute, true)
+	}
+	return spokenNumber(hour, false) + " " + spokenNumber(minute, true)
+}
+
+func partOfDayName(hour int) string {
+	switch {
+	case hour < 5:
+		return "ночи"
+	case hour < 12:
+		return "утра"
+	case hour < 17:
+		return "дня"
+	}
+	return "вечера"
+}
+
+// spokenNumber says numbers from 0 to 59, minutes are feminine: "двадцать одна"
+func spokenNumber(n int, feminine bool) string {
+	if n >= len(units) && n%10 == 0 {
+		return tens[n/10]
+	}
+	var prefix string
+	if n >= len(units) {
+		prefix = tens[n/10] + " "
+		n = n % 10
+	}
+	if feminine && n == 1 {
+		return prefix + "одна"
+	}
+	if feminine && n == 2 {
+		return prefix + "две"
+	}
+	return prefix + units[n]
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestSpokenTime(t *testing.T) {
+	var td = []struct {
+		Hour   int
+		Minute int
+		Spoken string
+	}{
+		{19, 0, "семь вечера"},
+		{12, 0, "двенадцать дня"},
+		{1, 0, "час ночи"},
+		{19, 30, "половине восьмого"},
+		{0, 30, "половине первого"},
+		{19, 45, "девятнадцать сорок пять"},
+		{21, 5, "двадцать один ноль пять"},
+		{10, 21, "десять двадцать одна"},
+		{22, 12, "двадцать два двенадцать"},
+	}
+	for _, tr := range td {
+		if spoken := spokenTime(tr.Hour, tr.Minute); spoken != tr.Spoken {
+			t.Errorf("wrong spoken time %d:%02d: %s", tr.Hour, tr.Minute, spoken)
+		}
+	}
+}
+
+func TestRenderTTS(t *testing.T) {
+	text := "В Каро 11 Октябрь фильм начинается в 19:30 в IMAX 3D. В Пионер в 21:00."
+	expected := "В к+аро 11 Октябрь фильм начинается в половине восьмого в айм+акс три д+э." + sentencePause + "В Пионер в девять вечера."
+	if tts := RenderTTS(text); tts != expected {
+		t.Fatalf("wrong tts: %s", tts)
+	}
+	if tts := RenderTTS("Сейчас в кино: «Дюна», «Оно»."); tts != "Сейчас в кино: Дюна, Оно." {
+		t.Fatalf("quotes should not be spoken: %s", tts)
+	}
+	if tts := RenderTTS("Пионер, м. Кутузовская и Кинотеатр им. Горького"); tts != "Пионер, метро Кутузовская и Кинотеатр имени Горького" {
+		t.Fatalf("wrong subway abbreviation: %s", tts)
+	}
+}
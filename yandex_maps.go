@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
 // YandexLocations contains information about location
@@ -84,52 +85,127 @@ const yandexRequestTemplate = "https://geocode-maps.yandex.ru/1.x/?format=json&g
 // UnknownLocationError fires when location with given name not found
 var UnknownLocationError = errors.New("unknown location")
 
-// GetUserLocation searches a location from the user phrase in Yandex Maps API
-func GetUserLocation(phrase string) (*Location, error) {
-	resp, err := http.Get(fmt.Sprintf(yandexRequestTemplate, url.QueryEscape(phrase)))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+// YandexGeocoder finds locations in Yandex Maps API
+type YandexGeocoder struct {
+	apiKey string
+	client *http.Client
+}
 
-	var yandexLocs YandexLocations
-	err = json.NewDecoder(resp.Body).Decode(&yandexLocs)
+// NewYandexGeocoder creates a geocoder authorized with an API key, requests longer than the timeout fail
+func NewYandexGeocoder(apiKey string, timeout time.Duration) *YandexGeocoder {
+	return &YandexGeocoder{apiKey: apiKey, client: &http.Client{Timeout: timeout}}
+}
+
+// Locate searches a location from the user phrase in Yandex Maps API.
+// The same station in several cities and towns with the same name in different regions are ambiguous.
+func (g *YandexGeocoder) Locate(phrase string) (*Location, error) {
+	yandexLocs, err := g.geocode(phrase)
 	if err != nil {
 		return nil, err
 	}
 
 	var city string
+	var position *Point
+	stations := make([]Location, 0)
+	towns := make([]Location, 0)
 	// we should always try to find a nearest subway station
 	// if there is no subway stations, find the first street in the city and return city
 	if len(yandexLocs.Response.GeoObjectCollection.FeatureMember) == 0 {
 		return nil, UnknownLocationError
 	}
 	for _, member := range yandexLocs.Response.GeoObjectCollection.FeatureMember {
-		kind := member.GeoObject.MetaDataProperty.GeocoderMetaData.Kind
-		if kind == "metro" {
+		metaData := member.GeoObject.MetaDataProperty.GeocoderMetaData
+		area := metaData.AddressDetails.Country.AdministrativeArea
+		memberCity := area.Locality.LocalityName
+		if area.SubAdministrativeArea.Locality.LocalityName != "" {
+			memberCity = area.SubAdministrativeArea.Locality.LocalityName
+		}
+		memberPosition, _ := ParsePos(member.GeoObject.Point.Pos)
+
+		switch metaData.Kind {
+		case "metro":
 			subway := strings.TrimSpace(strings.Replace(member.GeoObject.Name, "метро", "", -1))
-			area := member.GeoObject.MetaDataProperty.GeocoderMetaData.AddressDetails.Country.AdministrativeArea
-			if area.SubAdministrativeArea.Locality.LocalityName == "" {
-				city = area.Locality.LocalityName
-			} else {
-				city = area.SubAdministrativeArea.Locality.LocalityName
-			}
-			return &Location{
-				City:   city,
-				Subway: subway,
-			}, nil
-		} else if kind == "street" {
-			area := member.GeoObject.MetaDataProperty.GeocoderMetaData.AddressDetails.Country.AdministrativeArea
-			if area.SubAdministrativeArea.Locality.LocalityName == "" {
-				city = area.Locality.LocalityName
-			} else {
-				city = area.SubAdministrativeArea.Locality.LocalityName
+			stations = appendCandidate(stations, Location{
+				City:     memberCity,
+				Subway:   subway,
+				Position: memberPosition,
+			})
+		case "locality":
+			if len(towns) != 0 && towns[0].City != member.GeoObject.Name {
+				continue
 			}
+			towns = appendCandidate(towns, Location{
+				City:     member.GeoObject.Name,
+				Region:   area.AdministrativeAreaName,
+				Position: memberPosition,
+			})
+		case "street":
+			city, position = memberCity, memberPosition
 		}
 	}
 
+	if len(stations) > 1 {
+		return nil, AmbiguousLocationError{stations}
+	}
+	if len(stations) == 1 {
+		return &stations[0], nil
+	}
+	if len(towns) > 1 && city == "" {
+		return nil, AmbiguousLocationError{towns}
+	}
 	if city != "" {
-		return &Location{City: city}, nil
+		return &Location{City: city, Position: position}, nil
+	}
+	if len(towns) == 1 {
+		return &towns[0], nil
 	}
 	return nil, UnknownLocationError
 }
+
+// appendCandidate adds a location of another city or region, the first location of a place is the most relevant one
+func appendCandidate(candidates []Location, location Location) []Location {
+	if location.City == "" || len(candidates) == maxChoices {
+		return candidates
+	}
+	for _, candidate := range candidates {
+		if candidate.City == location.City && candidate.Region == location.Region {
+			return candidates
+		}
+	}
+	return append(candidates, location)
+}
+
+// Geocode finds a position of the address in Yandex Maps API
+func (g *YandexGeocoder) Geocode(address string) (*Point, error) {
+	yandexLocs, err := g.geocode(address)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range yandexLocs.Response.GeoObjectCollection.FeatureMember {
+		if position, ok := ParsePos(member.GeoObject.Point.Pos); ok {
+			return position, nil
+		}
+	}
+	return nil, UnknownLocationError
+}
+
+func (g *YandexGeocoder) geocode(query string) (*YandexLocations, error) {
+	link := fmt.Sprintf(yandexRequestTemplate, url.QueryEscape(query))
+	if g.apiKey != "" {
+		link += "&apikey=" + url.QueryEscape(g.apiKey)
+	}
+	resp, err := g.client.Get(link)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var yandexLocs YandexLocations
+	if err := json.NewDecoder(resp.Body).Decode(&yandexLocs); err != nil {
+		return nil, err
+	}
+	return &yandexLocs, nil
+}